@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"monkey/token"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -25,6 +29,9 @@ type Expression interface {
 // Root node for all ast
 type Program struct {
 	Statements []Statement
+
+	// every comment in source order, only recorded when the lexer preserves comments
+	Comments []token.Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -34,6 +41,18 @@ func (p *Program) TokenLiteral() string {
 		return ""
 	}
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -46,10 +65,18 @@ func (p *Program) String() string {
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
+	Trivia     *token.Trivia // comments and blank lines around the statement
 }
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -59,12 +86,15 @@ func (es *ExpressionStatement) String() string {
 
 // BlockStatemnt
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{'
 	Statements []Statement
+	EndToken   token.Token // '}'
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.EndToken.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -73,20 +103,29 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
-// LET
+// LET or CONST
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Pattern // set instead of Name by a destructuring let like let [a, b] = arr;
+	Value   Expression
+	Trivia  *token.Trivia // comments and blank lines around the statement
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.target().End()
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral())
 	out.WriteString(" ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.target().String())
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -96,14 +135,64 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Const reports whether the statement binds constants
+func (ls *LetStatement) Const() bool { return ls.Token.Type == token.CONST }
+
+// the bound name or pattern
+func (ls *LetStatement) target() Node {
+	if ls.Pattern != nil {
+		return ls.Pattern
+	}
+	return ls.Name
+}
+
+// IMPORT, binds the module at Path to Name
+type ImportStatement struct {
+	Token  token.Token
+	Path   *StringLiteral
+	Name   *Identifier
+	Trivia *token.Trivia // comments and blank lines around the statement
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position  { return is.Name.End() }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " " + is.Path.String() + " as " + is.Name.String() + ";"
+}
+
+// EXPORT, makes the names bound by Statement visible to the importers of the module
+type ExportStatement struct {
+	Token     token.Token
+	Statement *LetStatement
+	Trivia    *token.Trivia // comments and blank lines around the statement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position  { return es.Statement.End() }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 // RETURN
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
+	Trivia      *token.Trivia // comments and blank lines around the statement
 }
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral())
@@ -115,6 +204,96 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// THROW
+type ThrowStatement struct {
+	Token  token.Token
+	Value  Expression
+	Trivia *token.Trivia // comments and blank lines around the statement
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position  { return ts.Value.End() }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// WHILE
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+	Trivia    *token.Trivia // comments and blank lines around the statement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// FOR
+type ForStatement struct {
+	Token     token.Token
+	Variables []*Identifier // the element, or the key and the value
+	Iterable  Expression
+	Body      *BlockStatement
+	Trivia    *token.Trivia // comments and blank lines around the statement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	variables := []string{}
+	for _, v := range fs.Variables {
+		variables = append(variables, v.String())
+	}
+
+	out.WriteString("for (")
+	out.WriteString(strings.Join(variables, ", "))
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// BREAK
+type BreakStatement struct {
+	Token  token.Token
+	Trivia *token.Trivia // comments and blank lines around the statement
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.TokenLiteral() + ";" }
+
+// CONTINUE
+type ContinueStatement struct {
+	Token  token.Token
+	Trivia *token.Trivia // comments and blank lines around the statement
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() + ";" }
+
 // IDENTIFIER
 type Identifier struct {
 	Token token.Token
@@ -123,6 +302,8 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string       { return i.Value }
 
 // Integer literal
@@ -133,8 +314,22 @@ type IntegerLiteral struct {
 
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
+func (i *IntegerLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position  { return i.Token.End }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
 
+// Float literal
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // String literal
 type StringLiteral struct {
 	Token token.Token
@@ -143,7 +338,42 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string       { return quoteString(sl.Value) }
+
+// InterpolatedString is a string literal with embedded expressions like "a ${b} c"
+//
+// Segments has one more element than Expressions, they are interleaved as
+// Segments[0] Expressions[0] Segments[1] ... Expressions[n-1] Segments[n]
+type InterpolatedString struct {
+	Token       token.Token // STRING_HEAD
+	Segments    []string
+	Expressions []Expression
+	EndToken    token.Token // STRING_TAIL
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position  { return is.EndToken.End }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for i, segment := range is.Segments {
+		quoted := quoteString(segment)
+		out.WriteString(quoted[1 : len(quoted)-1])
+		if i < len(is.Expressions) {
+			out.WriteString("${")
+			out.WriteString(is.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
 
 // Bool literal
 type Boolean struct {
@@ -153,6 +383,8 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 // PrefixExpression
@@ -164,6 +396,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -183,6 +417,8 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -195,6 +431,30 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression, like x = 1, x += 1 or a[i] = 1
+type AssignExpression struct {
+	Token    token.Token // the assignment operator
+	Target   Expression  // *Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position  { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" ")
+	out.WriteString(ae.Operator)
+	out.WriteString(" ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 // IF-ELSE expression
 type IfExpression struct {
 	Token       token.Token
@@ -205,6 +465,13 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -219,16 +486,58 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TRY-CATCH-FINALLY expression, at least one of Catch and Finally is set
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Identifier // the variable of the caught value, nil if the catch clause does not name it
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) End() token.Position {
+	if te.Finally != nil {
+		return te.Finally.End()
+	}
+	return te.Catch.End()
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ")")
+		}
+		out.WriteString(" ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
 // Function Literal
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   []Expression // default value of each parameter, nil for a required one
+	Rest       *Identifier  // the ...rest parameter collecting extra arguments, nil if there is none
 	Body       *BlockStatement
 	Name       string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -237,27 +546,62 @@ func (fl *FunctionLiteral) String() string {
 		out.WriteString(fmt.Sprintf("<%s>", fl.Name))
 	}
 	out.WriteString("(")
+	params := []string{}
 	for i, p := range fl.Parameters {
-		out.WriteString(p.String())
-		if i != len(fl.Parameters)-1 {
-			out.WriteString(", ")
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
 		}
 	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
+	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// MacroLiteral, like macro(a, b) { quote(unquote(a) + unquote(b)) }
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position  { return ml.Body.End() }
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	return ml.TokenLiteral() + "(" + strings.Join(params, ", ") + ")" + ml.Body.String()
+}
+
 // CallExpression
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // '('
 	Function  Expression
 	Arguments []Expression
+	Receiver  bool        // set by receiver.f(a), parsed as f(receiver, a)
+	EndToken  token.Token // ')'
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	// the receiver of value.f() or value |> f() is the first argument, written before the function
+	if len(ce.Arguments) > 0 && ce.Arguments[0] != nil && ce.Arguments[0].Pos().Offset < ce.Function.Pos().Offset {
+		return ce.Arguments[0].Pos()
+	}
+	return ce.Function.Pos()
+}
+func (ce *CallExpression) End() token.Position { return ce.EndToken.End }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -278,10 +622,13 @@ func (ce *CallExpression) String() string {
 type ArrayLiteral struct {
 	Token    token.Token // '['
 	Elements []Expression
+	EndToken token.Token // ']'
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.EndToken.End }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -299,18 +646,25 @@ func (al *ArrayLiteral) String() string {
 
 // IndexExpression
 type IndexExpression struct {
-	Token token.Token // '['
-	Left  Expression
-	Index Expression
+	Token    token.Token // '[', '.' of a.key or '?.' of a?.key and a?.[index]
+	Left     Expression
+	Index    Expression
+	Optional bool        // set by ?., the expression is null instead of an error when Left is null
+	EndToken token.Token // ']' or the key
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return ie.EndToken.End }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -318,14 +672,52 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[low:high], Low and High are nil when omitted
+type SliceExpression struct {
+	Token    token.Token // '[' or '?.' of a?.[low:high]
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Optional bool        // set by ?., the expression is null instead of an error when Left is null
+	EndToken token.Token // ']'
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position  { return se.EndToken.End }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral
 type HashLiteral struct {
-	Token token.Token // '{'
-	Pairs map[Expression]Expression
+	Token    token.Token // '{'
+	Pairs    map[Expression]Expression
+	EndToken token.Token // '}'
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.EndToken.End }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -340,3 +732,166 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// Pattern is the target of a destructuring binding, an *Identifier, *ArrayPattern or *HashPattern,
+// a match arm may also use a *LiteralPattern
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+func (i *Identifier) patternNode() {}
+
+// BindingElement is an element of a pattern with an optional default used when the value is null, like b = 1 in [a, b = 1]
+type BindingElement struct {
+	Target  Pattern
+	Default Expression
+}
+
+func (be *BindingElement) String() string {
+	if be.Default == nil {
+		return be.Target.String()
+	}
+	return be.Target.String() + " = " + be.Default.String()
+}
+
+// ArrayPattern, like [head, second = 0, ...tail]
+type ArrayPattern struct {
+	Token    token.Token // '['
+	Elements []*BindingElement
+	Rest     *Identifier // nil without ...rest
+	EndToken token.Token // ']'
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) End() token.Position  { return ap.EndToken.End }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPatternPair binds the value of Key, {name} is short for {"name": name}
+type HashPatternPair struct {
+	Key   *StringLiteral
+	Value *BindingElement
+}
+
+func (hp *HashPatternPair) String() string {
+	if ident, ok := hp.Value.Target.(*Identifier); ok && ident.Value == hp.Key.Value {
+		return hp.Value.String()
+	}
+	return hp.Key.String() + ": " + hp.Value.String()
+}
+
+// HashPattern, like {name, age = 0, address: {city}}
+type HashPattern struct {
+	Token    token.Token // '{'
+	Pairs    []*HashPatternPair
+	EndToken token.Token // '}'
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) End() token.Position  { return hp.EndToken.End }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		pairs = append(pairs, pair.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// LiteralPattern matches a value equal to a literal, like 1, -2.5, "user" or true
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Pos() token.Position  { return lp.Value.Pos() }
+func (lp *LiteralPattern) End() token.Position  { return lp.Value.End() }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+// MatchArm is a single pattern [if guard] => body of a match expression
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // nil without a guard
+	Body    *BlockStatement
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+	return out.String()
+}
+
+// MatchExpression, like match (value) { 1 => "one", [a, b] => a + b, _ => null }
+type MatchExpression struct {
+	Token    token.Token // 'match'
+	Subject  Expression
+	Arms     []*MatchArm
+	EndToken token.Token // '}'
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position  { return me.EndToken.End }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match (" + me.Subject.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+// quoteString returns a double-quoted string literal which the lexer decodes back to s
+func quoteString(s string) string {
+	var out bytes.Buffer
+
+	out.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && width == 1:
+			fmt.Fprintf(&out, "\\x%02x", s[i])
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '$' && strings.HasPrefix(s[i+width:], "{"):
+			out.WriteString(`\$`)
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r < utf8.RuneSelf && !unicode.IsPrint(r):
+			fmt.Fprintf(&out, "\\x%02x", r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&out, "\\u{%x}", r)
+		default:
+			out.WriteRune(r)
+		}
+		i += width
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
@@ -26,3 +26,24 @@ func TestProgramString(t *testing.T) {
 
 	require.Equal(t, "let myVar = anotherVar;", program.String(), "Wrong program.String()")
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"a\nb\tc\rd", `"a\nb\tc\rd"`},
+		{`say "hi" \ bye`, `"say \"hi\" \\ bye"`},
+		{"\x00\x7f\xff", `"\x00\x7f\xff"`},
+		{"한글​", `"한글\u{200b}"`},
+	}
+
+	for _, tt := range tests {
+		literal := &StringLiteral{
+			Token: token.Token{Type: token.STRING, Literal: tt.value},
+			Value: tt.value,
+		}
+		require.Equal(t, tt.expected, literal.String(), "Wrong StringLiteral.String()")
+	}
+}
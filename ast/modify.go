@@ -0,0 +1,220 @@
+package ast
+
+// ModifierFunc returns the node replacing node, or node itself to keep it
+type ModifierFunc func(Node) Node
+
+// Modify returns node with its children replaced by the results of Modify on them, passed to modifier
+//
+// node itself is not changed, every node on the way to a modified one is copied so a quoted body can be
+// modified again. identifiers being bound, like parameters and let names, are not modified
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		program := *n
+		program.Statements = modifyStatements(n.Statements, modifier)
+		node = &program
+
+	case *ExpressionStatement:
+		statement := *n
+		statement.Expression, _ = Modify(n.Expression, modifier).(Expression)
+		node = &statement
+
+	case *BlockStatement:
+		block := *n
+		block.Statements = modifyStatements(n.Statements, modifier)
+		node = &block
+
+	case *LetStatement:
+		statement := *n
+		if n.Pattern != nil {
+			statement.Pattern = modifyPattern(n.Pattern, modifier)
+		}
+		statement.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = &statement
+
+	case *ExportStatement:
+		statement := *n
+		statement.Statement, _ = Modify(n.Statement, modifier).(*LetStatement)
+		node = &statement
+
+	case *ReturnStatement:
+		statement := *n
+		statement.ReturnValue, _ = Modify(n.ReturnValue, modifier).(Expression)
+		node = &statement
+
+	case *ThrowStatement:
+		statement := *n
+		statement.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = &statement
+
+	case *WhileStatement:
+		statement := *n
+		statement.Condition, _ = Modify(n.Condition, modifier).(Expression)
+		statement.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = &statement
+
+	case *ForStatement:
+		statement := *n
+		statement.Iterable, _ = Modify(n.Iterable, modifier).(Expression)
+		statement.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = &statement
+
+	case *InterpolatedString:
+		str := *n
+		str.Expressions = modifyExpressions(n.Expressions, modifier)
+		node = &str
+
+	case *PrefixExpression:
+		expression := *n
+		expression.Right, _ = Modify(n.Right, modifier).(Expression)
+		node = &expression
+
+	case *InfixExpression:
+		expression := *n
+		expression.Left, _ = Modify(n.Left, modifier).(Expression)
+		expression.Right, _ = Modify(n.Right, modifier).(Expression)
+		node = &expression
+
+	case *AssignExpression:
+		expression := *n
+		expression.Target, _ = Modify(n.Target, modifier).(Expression)
+		expression.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = &expression
+
+	case *IfExpression:
+		expression := *n
+		expression.Condition, _ = Modify(n.Condition, modifier).(Expression)
+		expression.Consequence, _ = Modify(n.Consequence, modifier).(*BlockStatement)
+		if n.Alternative != nil {
+			expression.Alternative, _ = Modify(n.Alternative, modifier).(*BlockStatement)
+		}
+		node = &expression
+
+	case *TryExpression:
+		expression := *n
+		expression.Block, _ = Modify(n.Block, modifier).(*BlockStatement)
+		if n.Catch != nil {
+			expression.Catch, _ = Modify(n.Catch, modifier).(*BlockStatement)
+		}
+		if n.Finally != nil {
+			expression.Finally, _ = Modify(n.Finally, modifier).(*BlockStatement)
+		}
+		node = &expression
+
+	case *MatchExpression:
+		expression := *n
+		expression.Subject, _ = Modify(n.Subject, modifier).(Expression)
+		expression.Arms = make([]*MatchArm, len(n.Arms))
+		for i, arm := range n.Arms {
+			newArm := *arm
+			if arm.Guard != nil {
+				newArm.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			newArm.Body, _ = Modify(arm.Body, modifier).(*BlockStatement)
+			expression.Arms[i] = &newArm
+		}
+		node = &expression
+
+	case *FunctionLiteral:
+		function := *n
+		if n.Defaults != nil {
+			function.Defaults = make([]Expression, len(n.Defaults))
+			for i, value := range n.Defaults {
+				if value != nil {
+					function.Defaults[i], _ = Modify(value, modifier).(Expression)
+				}
+			}
+		}
+		function.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = &function
+
+	case *CallExpression:
+		expression := *n
+		expression.Function, _ = Modify(n.Function, modifier).(Expression)
+		expression.Arguments = modifyExpressions(n.Arguments, modifier)
+		node = &expression
+
+	case *ArrayLiteral:
+		array := *n
+		array.Elements = modifyExpressions(n.Elements, modifier)
+		node = &array
+
+	case *IndexExpression:
+		expression := *n
+		expression.Left, _ = Modify(n.Left, modifier).(Expression)
+		expression.Index, _ = Modify(n.Index, modifier).(Expression)
+		node = &expression
+
+	case *SliceExpression:
+		expression := *n
+		expression.Left, _ = Modify(n.Left, modifier).(Expression)
+		if n.Low != nil {
+			expression.Low, _ = Modify(n.Low, modifier).(Expression)
+		}
+		if n.High != nil {
+			expression.High, _ = Modify(n.High, modifier).(Expression)
+		}
+		node = &expression
+
+	case *HashLiteral:
+		hash := *n
+		hash.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for key, value := range n.Pairs {
+			newKey, _ := Modify(key, modifier).(Expression)
+			newValue, _ := Modify(value, modifier).(Expression)
+			hash.Pairs[newKey] = newValue
+		}
+		node = &hash
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	modified := make([]Statement, len(statements))
+	for i, statement := range statements {
+		modified[i], _ = Modify(statement, modifier).(Statement)
+	}
+	return modified
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) []Expression {
+	if expressions == nil {
+		return nil
+	}
+	modified := make([]Expression, len(expressions))
+	for i, expression := range expressions {
+		modified[i], _ = Modify(expression, modifier).(Expression)
+	}
+	return modified
+}
+
+// modify the default values of the elements of a destructuring pattern
+func modifyPattern(pattern Pattern, modifier ModifierFunc) Pattern {
+	modifyElement := func(element *BindingElement) *BindingElement {
+		newElement := *element
+		if element.Default != nil {
+			newElement.Default, _ = Modify(element.Default, modifier).(Expression)
+		}
+		newElement.Target = modifyPattern(element.Target, modifier)
+		return &newElement
+	}
+
+	switch p := pattern.(type) {
+	case *ArrayPattern:
+		newPattern := *p
+		newPattern.Elements = make([]*BindingElement, len(p.Elements))
+		for i, element := range p.Elements {
+			newPattern.Elements[i] = modifyElement(element)
+		}
+		return &newPattern
+	case *HashPattern:
+		newPattern := *p
+		newPattern.Pairs = make([]*HashPatternPair, len(p.Pairs))
+		for i, pair := range p.Pairs {
+			newPattern.Pairs[i] = &HashPatternPair{Key: pair.Key, Value: modifyElement(pair.Value)}
+		}
+		return &newPattern
+	}
+	return pattern
+}
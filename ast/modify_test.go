@@ -0,0 +1,143 @@
+package ast
+
+import (
+	"monkey/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	block := func(e Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: e}}}
+	}
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return &IntegerLiteral{Value: 2}
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&SliceExpression{Left: one(), Low: one(), High: one()},
+			&SliceExpression{Left: two(), Low: two(), High: two()},
+		},
+		{
+			&SliceExpression{Left: one(), High: one()},
+			&SliceExpression{Left: two(), High: two()},
+		},
+		{
+			&IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&IfExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: ident("a"), Value: one()},
+			&LetStatement{Name: ident("a"), Value: two()},
+		},
+		{
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []*BindingElement{{Target: ident("a"), Default: one()}}},
+				Value:   one(),
+			},
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []*BindingElement{{Target: ident("a"), Default: two()}}},
+				Value:   two(),
+			},
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{ident("a")}, Defaults: []Expression{one()}, Body: block(one())},
+			&FunctionLiteral{Parameters: []*Identifier{ident("a")}, Defaults: []Expression{two()}, Body: block(two())},
+		},
+		{
+			&CallExpression{Function: ident("f"), Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: ident("f"), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&AssignExpression{Target: &IndexExpression{Left: ident("a"), Index: one()}, Operator: "=", Value: one()},
+			&AssignExpression{Target: &IndexExpression{Left: ident("a"), Index: two()}, Operator: "=", Value: two()},
+		},
+		{
+			&WhileStatement{Condition: one(), Body: block(one())},
+			&WhileStatement{Condition: two(), Body: block(two())},
+		},
+		{
+			&ForStatement{Variables: []*Identifier{ident("x")}, Iterable: one(), Body: block(one())},
+			&ForStatement{Variables: []*Identifier{ident("x")}, Iterable: two(), Body: block(two())},
+		},
+		{
+			&InterpolatedString{Segments: []string{"a", "b"}, Expressions: []Expression{one()}},
+			&InterpolatedString{Segments: []string{"a", "b"}, Expressions: []Expression{two()}},
+		},
+		{
+			&ExportStatement{Statement: &LetStatement{Name: ident("a"), Value: one()}},
+			&ExportStatement{Statement: &LetStatement{Name: ident("a"), Value: two()}},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&TryExpression{Block: block(one()), Param: ident("e"), Catch: block(one()), Finally: block(one())},
+			&TryExpression{Block: block(two()), Param: ident("e"), Catch: block(two()), Finally: block(two())},
+		},
+		{
+			&MatchExpression{Subject: one(), Arms: []*MatchArm{{Pattern: ident("x"), Guard: one(), Body: block(one())}}},
+			&MatchExpression{Subject: two(), Arms: []*MatchArm{{Pattern: ident("x"), Guard: two(), Body: block(two())}}},
+		},
+	}
+
+	for _, tt := range tests {
+		before := tt.input.String()
+		modified := Modify(tt.input, turnOneIntoTwo)
+		require.Equal(t, tt.expected, modified)
+		require.Equal(t, before, tt.input.String(), "the input was changed")
+	}
+
+	hashLiteral := &HashLiteral{
+		Token: token.Token{Type: token.L_BRACE, Literal: "{"},
+		Pairs: map[Expression]Expression{one(): one()},
+	}
+	modified := Modify(hashLiteral, turnOneIntoTwo).(*HashLiteral)
+	for key, value := range modified.Pairs {
+		require.Equal(t, int64(2), key.(*IntegerLiteral).Value)
+		require.Equal(t, int64(2), value.(*IntegerLiteral).Value)
+	}
+	for key, value := range hashLiteral.Pairs {
+		require.Equal(t, int64(1), key.(*IntegerLiteral).Value)
+		require.Equal(t, int64(1), value.(*IntegerLiteral).Value)
+	}
+}
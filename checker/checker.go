@@ -0,0 +1,276 @@
+// Package checker checks a program before it is compiled or evaluated, so the compiler and the evaluator reject
+// the same programs
+package checker
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Error is an error found in the source of a program
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// the names defined by a function, or by the program, and whether each one is a constant
+type scope struct {
+	names map[string]bool
+	outer *scope
+}
+
+// walks the nodes in the order the compiler compiles them. blocks share the scope of their function
+type constChecker struct {
+	scope   *scope
+	isConst func(name string) bool
+	err     *Error
+}
+
+// CheckConstants returns an error for the first assignment or redefinition of a constant in node, nil if there
+// is none. isConst reports whether a name defined outside of node, like by an earlier line of the REPL, is a
+// constant
+func CheckConstants(node ast.Node, isConst func(name string) bool) *Error {
+	c := &constChecker{scope: &scope{names: map[string]bool{}}, isConst: isConst}
+	c.check(node)
+	return c.err
+}
+
+// report whether the nearest definition of name is a constant
+func (c *constChecker) constant(name string) bool {
+	for s := c.scope; s != nil; s = s.outer {
+		if constant, ok := s.names[name]; ok {
+			return constant
+		}
+	}
+	return c.isConst(name)
+}
+
+// define the name of node in the current scope, a constant can be neither redefined nor shadowed
+func (c *constChecker) define(node *ast.Identifier, constant bool) {
+	if c.err != nil {
+		return
+	}
+	if c.constant(node.Value) {
+		c.fail(node, "cannot redefine constant %s", node.Value)
+		return
+	}
+	c.scope.names[node.Value] = constant
+}
+
+func (c *constChecker) enterScope() {
+	c.scope = &scope{names: map[string]bool{}, outer: c.scope}
+}
+
+func (c *constChecker) leaveScope() {
+	c.scope = c.scope.outer
+}
+
+func (c *constChecker) fail(node ast.Node, format string, a ...interface{}) {
+	c.err = &Error{Pos: node.Pos(), Message: fmt.Sprintf(format, a...)}
+}
+
+// check node, the first error stops the check
+func (c *constChecker) check(node ast.Node) {
+	if c.err != nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, statement := range node.Statements {
+			c.check(statement)
+		}
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			c.check(statement)
+		}
+	case *ast.ExpressionStatement:
+		c.check(node.Expression)
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			c.check(node.Value)
+			c.checkPattern(node.Pattern, node.Const())
+			return
+		}
+		c.define(node.Name, node.Const())
+		c.check(node.Value)
+	case *ast.ExportStatement:
+		c.check(node.Statement)
+	case *ast.ImportStatement:
+		c.define(node.Name, false)
+	case *ast.ReturnStatement:
+		c.check(node.ReturnValue)
+	case *ast.ThrowStatement:
+		c.check(node.Value)
+	case *ast.WhileStatement:
+		c.check(node.Condition)
+		c.check(node.Body)
+	case *ast.ForStatement:
+		c.check(node.Iterable)
+		for _, variable := range node.Variables {
+			c.define(variable, false)
+		}
+		c.check(node.Body)
+	case *ast.InterpolatedString:
+		for _, expression := range node.Expressions {
+			c.check(expression)
+		}
+	case *ast.PrefixExpression:
+		c.check(node.Right)
+	case *ast.InfixExpression:
+		c.check(node.Left)
+		c.check(node.Right)
+	case *ast.AssignExpression:
+		if target, ok := node.Target.(*ast.Identifier); ok && c.constant(target.Value) {
+			c.fail(target, "cannot assign to constant %s", target.Value)
+			return
+		}
+		c.check(node.Target)
+		c.check(node.Value)
+	case *ast.IfExpression:
+		c.check(node.Condition)
+		c.check(node.Consequence)
+		if node.Alternative != nil {
+			c.check(node.Alternative)
+		}
+	case *ast.TryExpression:
+		c.check(node.Block)
+		if node.Catch != nil {
+			if node.Param != nil {
+				c.define(node.Param, false)
+			}
+			c.check(node.Catch)
+		}
+		if node.Finally != nil {
+			c.check(node.Finally)
+		}
+	case *ast.MatchExpression:
+		c.check(node.Subject)
+		for _, arm := range node.Arms {
+			c.checkMatchArm(arm)
+		}
+	case *ast.FunctionLiteral:
+		c.enterScope()
+		defer c.leaveScope()
+		if node.Name != "" {
+			c.scope.names[node.Name] = false
+		}
+		for _, p := range node.Parameters {
+			c.define(p, false)
+		}
+		if node.Rest != nil {
+			c.define(node.Rest, false)
+		}
+		for _, value := range node.Defaults {
+			if value != nil {
+				c.check(value)
+			}
+		}
+		c.check(node.Body)
+	case *ast.MacroLiteral:
+		c.enterScope()
+		defer c.leaveScope()
+		for _, p := range node.Parameters {
+			c.define(p, false)
+		}
+		c.check(node.Body)
+	case *ast.CallExpression:
+		c.check(node.Function)
+		for _, argument := range node.Arguments {
+			c.check(argument)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			c.check(element)
+		}
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			c.check(key)
+			c.check(value)
+		}
+	case *ast.IndexExpression:
+		c.check(node.Left)
+		c.check(node.Index)
+	case *ast.SliceExpression:
+		c.check(node.Left)
+		if node.Low != nil {
+			c.check(node.Low)
+		}
+		if node.High != nil {
+			c.check(node.High)
+		}
+	}
+}
+
+// check the defaults of a destructuring pattern and define its names
+func (c *constChecker) checkPattern(pattern ast.Pattern, constant bool) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.define(pattern, constant)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			c.checkBindingElement(element, constant)
+		}
+		if pattern.Rest != nil {
+			c.define(pattern.Rest, constant)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			c.checkBindingElement(pair.Value, constant)
+		}
+	}
+}
+
+func (c *constChecker) checkBindingElement(element *ast.BindingElement, constant bool) {
+	if element.Default != nil {
+		c.check(element.Default)
+	}
+	c.checkPattern(element.Target, constant)
+}
+
+// check the guard and the body of an arm. its names shadow the enclosing ones in the guard and are defined in the
+// enclosing scope once the guard passed, _ binds nothing
+func (c *constChecker) checkMatchArm(arm *ast.MatchArm) {
+	names := patternNames(arm.Pattern)
+
+	if arm.Guard != nil {
+		c.enterScope()
+		for _, name := range names {
+			c.scope.names[name.Value] = false
+		}
+		c.check(arm.Guard)
+		c.leaveScope()
+	}
+	for _, name := range names {
+		c.define(name, false)
+	}
+	c.check(arm.Body)
+}
+
+// return the names bound by a match pattern
+func patternNames(pattern ast.Pattern) []*ast.Identifier {
+	names := []*ast.Identifier{}
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			names = append(names, pattern)
+		}
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			names = append(names, patternNames(element.Target)...)
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			names = append(names, pattern.Rest)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = append(names, patternNames(pair.Value.Target)...)
+		}
+	}
+	return names
+}
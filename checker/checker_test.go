@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestCheckConstants(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"const x = 1; x", ""},
+		{"let x = 1; const x = 2; x", ""},
+		{"let x = 1; x = 2", ""},
+		{"const x = 1; fn() { x }", ""},
+		{"for (x in [1, 2]) { const y = x; y }", ""},
+		{"fn() { const x = 1 }; let x = 2", ""},
+		{"const x = 1; match (2) { _ => x }", ""},
+		{"const x = 1; x = 2", "1:14: cannot assign to constant x"},
+		{"const x = 1; x += 2", "1:14: cannot assign to constant x"},
+		{"const x = 1; const x = 2;", "1:20: cannot redefine constant x"},
+		{"const x = 1; let x = 2;", "1:18: cannot redefine constant x"},
+		{"const [a, ...b] = [1]; b = 2", "1:24: cannot assign to constant b"},
+		{"const {a} = {}; let [a] = [];", "1:22: cannot redefine constant a"},
+		{"const x = 1; fn() { let x = 2; }", "1:25: cannot redefine constant x"},
+		{"const x = 1; fn(x) { x }", "1:17: cannot redefine constant x"},
+		{"const x = 1; fn(...x) { x }", "1:20: cannot redefine constant x"},
+		{"fn() { const x = 1; fn() { x = 2 } }", "1:28: cannot assign to constant x"},
+		{"const x = 1; for (x in []) {}", "1:19: cannot redefine constant x"},
+		{"const e = 1; try {} catch (e) {}", "1:28: cannot redefine constant e"},
+		{"const x = 1; match (2) { x => x }", "1:26: cannot redefine constant x"},
+		{"const x = 1; if (false) { let x = 2 }", "1:31: cannot redefine constant x"},
+		{"const x = 1; while (false) { x += 1 }", "1:30: cannot assign to constant x"},
+		{"const x = 1; [1, fn() { x = 2 }]", "1:25: cannot assign to constant x"},
+		{"const x = 1; macro(x) { x }", "1:20: cannot redefine constant x"},
+		{"macro() { const x = 1; x = 2 }", "1:24: cannot assign to constant x"},
+	}
+
+	for _, tt := range tests {
+		err := CheckConstants(parse(t, tt.input), func(string) bool { return false })
+		if tt.expectedError == "" {
+			require.Nil(t, err, tt.input)
+			continue
+		}
+		require.NotNil(t, err, tt.input)
+		require.Equal(t, tt.expectedError, err.Error(), tt.input)
+	}
+}
+
+func TestCheckConstantsDefinedOutside(t *testing.T) {
+	isConst := func(name string) bool { return name == "LIMIT" }
+
+	require.Nil(t, CheckConstants(parse(t, "LIMIT + 1"), isConst))
+	require.Nil(t, CheckConstants(parse(t, "fn(LIMIT) { LIMIT }"), func(string) bool { return false }))
+
+	err := CheckConstants(parse(t, "LIMIT = 2"), isConst)
+	require.NotNil(t, err)
+	require.Equal(t, "1:1: cannot assign to constant LIMIT", err.Error())
+
+	err = CheckConstants(parse(t, "fn(LIMIT) { LIMIT }"), isConst)
+	require.NotNil(t, err)
+	require.Equal(t, "1:4: cannot redefine constant LIMIT", err.Error())
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), "parser errors")
+	return program
+}
@@ -66,35 +66,71 @@ const (
 	OpSub
 	OpMul
 	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
 
 	OpEqual
 	OpNotEqual
 	OpGreaterThan
+	OpGreaterThanOrEqual
+	OpLessThan
+	OpLessThanOrEqual
 
 	OpMinus
 	OpBang
+	OpBitNot
 
 	OpJumpNotTruthy
 	OpJump
+	OpJumpNotNull
 
 	OpGetGlobal
 	OpSetGlobal
 	OpGetLocal
 	OpSetLocal
+	OpGetLocalCell
 
 	OpArray
 	OpHash
 	OpIndex
+	OpSlice
+	OpSetIndex
+	OpRest
+	OpMatchArray
+	OpMatchHash
 
 	OpCall
 	OpReturn
 	OpReturnValue
 
+	OpTry
+	OpTryFinally
+	OpEndTry
+	OpThrow
+
+	OpImport
+	OpEndModule
+
 	OpGetBuiltin
 
 	OpClosure
 	OpGetFree
+	OpSetFree
+	OpGetFreeCell
 	OpCurrentClosure
+
+	OpIter
+	OpIterNext
+	OpStackDepth
+	OpUnwind
+
+	OpDup
+	OpDup2
 )
 
 var definitions = map[Opcode]*Definition{
@@ -109,35 +145,95 @@ var definitions = map[Opcode]*Definition{
 	OpSub: {"OpSub", []int{}},
 	OpMul: {"OpMul", []int{}},
 	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+	OpPow: {"OpPow", []int{}},
+
+	OpBitAnd:     {"OpBitAnd", []int{}},
+	OpBitOr:      {"OpBitOr", []int{}},
+	OpBitXor:     {"OpBitXor", []int{}},
+	OpShiftLeft:  {"OpShiftLeft", []int{}},
+	OpShiftRight: {"OpShiftRight", []int{}},
 
 	OpEqual:       {"OpEqual", []int{}},
 	OpNotEqual:    {"OpNotEqual", []int{}},
 	OpGreaterThan: {"OpGreaterThan", []int{}},
 
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpLessThan:           {"OpLessThan", []int{}},
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},
+
 	OpMinus: {"OpMinus", []int{}},
 	OpBang:  {"OpBang", []int{}},
+	// bitwise complement of an integer
+	OpBitNot: {"OpBitNot", []int{}},
 
 	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
 	OpJump:          {"OpJump", []int{2}},
+	// jump if the object on the top of the stack is not null and keep it, otherwise pop it
+	OpJumpNotNull: {"OpJumpNotNull", []int{2}},
 
 	OpGetGlobal: {"OpGetGlobal", []int{2}},
 	OpSetGlobal: {"OpSetGlobal", []int{2}},
 	OpGetLocal:  {"OpGetLocal", []int{1}},
 	OpSetLocal:  {"OpSetLocal", []int{1}},
+	// push the cell holding a local, the local is moved into a new cell if it is not captured yet
+	OpGetLocalCell: {"OpGetLocalCell", []int{1}},
 
 	OpArray: {"OpArray", []int{2}},
 	OpHash:  {"OpHash", []int{2}},
 	OpIndex: {"OpIndex", []int{}},
+	// pop the high and the low bound, null when omitted, and an array or a string and push the slice
+	OpSlice: {"OpSlice", []int{}},
+	// pop an object, an index and a value, set the element of the object and push the value
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// replace an array with a new array of its elements from the operand on
+	OpRest: {"OpRest", []int{2}},
+	// replace an object with whether it is an array of the first operand elements, or at least as many
+	// if the second operand is 1
+	OpMatchArray: {"OpMatchArray", []int{2, 1}},
+	// pop the operand keys and an object and push whether it is a hash containing all the keys
+	OpMatchHash: {"OpMatchHash", []int{2}},
 
 	OpCall:        {"OpCall", []int{1}},
 	OpReturn:      {"OpReturn", []int{}},
 	OpReturnValue: {"OpReturnValue", []int{}},
 
+	// install a handler jumping to the operand with the raised value on the stack when an error is raised
+	OpTry: {"OpTry", []int{2}},
+	// install a handler like OpTry, with the raised error on the stack, which OpThrow raises again as it is
+	OpTryFinally: {"OpTryFinally", []int{2}},
+	// remove the handler installed by the last OpTry
+	OpEndTry: {"OpEndTry", []int{}},
+	// pop an object and raise it, or raise again the error of an OpTryFinally handler
+	OpThrow: {"OpThrow", []int{}},
+
+	// push the module of the operand constant, running the module body first if it is not loaded yet
+	OpImport: {"OpImport", []int{2}},
+	// return from the body of a module to its importer, leaving the module on the stack
+	OpEndModule: {"OpEndModule", []int{}},
+
 	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
 
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
+	OpSetFree:        {"OpSetFree", []int{1}},
+	OpGetFreeCell:    {"OpGetFreeCell", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	// replace the iterable on the stack with an iterator over it
+	OpIter: {"OpIter", []int{}},
+	// pop an iterator and push the next key and value (or only one of them, see object.Iterator.Single),
+	// or jump to the first operand once it is exhausted. the second operand is the number of objects to push
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+	// push the number of objects on the stack of the current frame
+	OpStackDepth: {"OpStackDepth", []int{}},
+	// pop a number pushed by OpStackDepth and drop the objects pushed since
+	OpUnwind: {"OpUnwind", []int{}},
+
+	// push the object on the top of the stack again
+	OpDup: {"OpDup", []int{}},
+	// push the two objects on the top of the stack again
+	OpDup2: {"OpDup2", []int{}},
 }
 
 func Lookup(op byte) (*Definition, error) {
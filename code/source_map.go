@@ -0,0 +1,16 @@
+package code
+
+import "monkey/token"
+
+// SourceMap maps the offset of an instruction to the source position it was compiled from
+type SourceMap map[int]token.Position
+
+// Lookup returns the position of the instruction containing the given offset
+func (sm SourceMap) Lookup(offset int) (token.Position, bool) {
+	for i := offset; i >= 0; i-- {
+		if pos, ok := sm[i]; ok {
+			return pos, pos.IsValid()
+		}
+	}
+	return token.Position{}, false
+}
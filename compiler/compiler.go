@@ -1,10 +1,13 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
+	"monkey/checker"
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 	"sort"
 )
 
@@ -15,13 +18,39 @@ type EmittedInstruction struct {
 
 type Bytecode struct {
 	Instructions code.Instructions
+	SourceMap    code.SourceMap
 	Constants    []object.Object
 }
 
 type CompilationScope struct {
 	instructions        code.Instructions
+	sourceMap           code.SourceMap
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	// loops being compiled in this scope, innermost last
+	loops []*loop
+
+	// try expressions being compiled in this scope, innermost last
+	tries []*tryBlock
+}
+
+// jump targets of a loop being compiled
+type loop struct {
+	start      int   // position of the condition, where continue jumps to
+	breakJumps []int // positions of the jumps emitted for break, patched once the loop end is known
+
+	// hidden variable holding the stack depth at the start of the loop, break and continue drop the operands
+	// of the expressions they leave down to it
+	depth Symbol
+}
+
+// a try expression being compiled, return, break and continue leave it by removing its handler and
+// running its finally block
+type tryBlock struct {
+	finally   *ast.BlockStatement
+	handler   bool // whether a handler is installed at this point of the try expression
+	loopDepth int  // number of loops around the try expression in its scope
 }
 
 type Compiler struct {
@@ -31,11 +60,41 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// position of the node being compiled, recorded in the source map of each emitted instruction
+	position token.Position
+
+	// number of match expressions around the node being compiled, used to name their hidden subjects
+	matchDepth int
+
+	// loads the modules of import statements, nil if the program cannot import modules
+	importer Importer
+
+	// index of the global bound to each exported name
+	exports map[string]int
+}
+
+// Importer loads the modules imported by the program being compiled
+type Importer interface {
+	// Import returns the module imported as path by the file named from. an *Error from compiling the module is
+	// reported as it is, other errors at the import statement
+	Import(path string, from string) (*object.CompiledModule, error)
+}
+
+// Error is a compilation error at a position of the source
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
 }
 
 func New() *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.SourceMap{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -50,6 +109,7 @@ func New() *Compiler {
 		symbolTable: symbolTable,
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
+		exports:     map[string]int{},
 	}
 }
 
@@ -60,9 +120,24 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	return compiler
 }
 
+// SetImporter sets the importer loading the modules of import statements
+func (c *Compiler) SetImporter(importer Importer) {
+	c.importer = importer
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	if node != nil {
+		outerPosition := c.position
+		c.position = node.Pos()
+		defer func() { c.position = outerPosition }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
+		if err := checker.CheckConstants(node, c.symbolTable.isConst); err != nil {
+			return &Error{Pos: err.Pos, Message: err.Message}
+		}
+
 		for _, s := range node.Statements {
 			err := c.Compile(s)
 			if err != nil {
@@ -99,6 +174,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		if c.lastInstructionIs(code.OpPop) {
 			c.removeLastPop()
+		} else {
+			// the block does not end with an expression, like a block ending with let or break
+			c.emit(code.OpNull)
 		}
 
 		jumpPos := c.emit(code.OpJump, 9999)
@@ -115,11 +193,96 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 			if c.lastInstructionIs(code.OpPop) {
 				c.removeLastPop()
+			} else {
+				c.emit(code.OpNull)
 			}
 		}
 		altLastPos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, altLastPos)
 
+	case *ast.MatchExpression:
+		err := c.compileMatch(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.MacroLiteral:
+		return c.errorf(node, "macro literal outside of a top-level let statement")
+
+	case *ast.WhileStatement:
+		depth := c.saveStackDepth()
+
+		start := len(c.currentInstructions())
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.compileLoopBody(node.Body, start, depth, jumpNotTruthyPos)
+		if err != nil {
+			return err
+		}
+
+	case *ast.ForStatement:
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIter)
+
+		// the iterator is kept in a hidden variable, '$' can not appear in identifiers
+		iterator := c.define(fmt.Sprintf("$iterator%d", len(c.scopes[c.scopeIndex].loops)), false)
+		c.storeSymbol(iterator)
+
+		// the variables are null until the first iteration, an empty iterable leaves them null
+		variables := make([]Symbol, len(node.Variables))
+		for i, variable := range node.Variables {
+			variables[i] = c.define(variable.Value, false)
+			c.emit(code.OpNull)
+			c.storeSymbol(variables[i])
+		}
+
+		depth := c.saveStackDepth()
+
+		start := len(c.currentInstructions())
+		c.loadSymbol(iterator)
+		iterNextPos := c.emit(code.OpIterNext, 9999, len(node.Variables))
+		for i := len(variables) - 1; i >= 0; i-- {
+			c.storeSymbol(variables[i])
+		}
+
+		err = c.compileLoopBody(node.Body, start, depth, iterNextPos)
+		if err != nil {
+			return err
+		}
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return c.errorf(node, "break is not in a loop")
+		}
+		c.loadSymbol(l.depth)
+		c.emit(code.OpUnwind)
+		err := c.leaveTries(len(c.scopes[c.scopeIndex].loops))
+		if err != nil {
+			return err
+		}
+		l.breakJumps = append(l.breakJumps, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return c.errorf(node, "continue is not in a loop")
+		}
+		c.loadSymbol(l.depth)
+		c.emit(code.OpUnwind)
+		err := c.leaveTries(len(c.scopes[c.scopeIndex].loops))
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, l.start)
+
 	case *ast.PrefixExpression:
 		err := c.Compile(node.Right)
 		if err != nil {
@@ -131,45 +294,75 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpBang)
 		case "-":
 			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return c.errorf(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		if node.Pattern != nil {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			return c.compilePattern(node.Pattern, node.Const())
+		}
+
+		symbol := c.define(node.Name.Value, node.Const())
 
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+		c.storeSymbol(symbol)
+
+	case *ast.ImportStatement:
+		if c.importer == nil {
+			return c.errorf(node, "cannot import %s: modules are not available", node.Path)
+		}
+		module, err := c.importer.Import(node.Path.Value, node.Pos().Filename)
+		var compileErr *Error
+		if errors.As(err, &compileErr) {
+			return err
+		}
+		if err != nil {
+			return c.errorf(node, "%s", err)
 		}
+		symbol := c.symbolTable.DefineModule(node.Name.Value)
+		c.emit(code.OpImport, c.addConstant(module))
+		c.storeSymbol(symbol)
+
+	case *ast.ExportStatement:
+		err := c.Compile(node.Statement)
+		if err != nil {
+			return err
+		}
+		for _, name := range boundNames(node.Statement) {
+			symbol, _ := c.symbolTable.Resolve(name.Value)
+			if symbol.Scope != GlobalScope {
+				return c.errorf(node, "export of %s is not at the top level", name.Value)
+			}
+			c.exports[name.Value] = symbol.Index
+		}
+
+	case *ast.AssignExpression:
+		return c.compileAssignment(node)
 
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return c.errorf(node, "undefined variable %s", node.Value)
 		}
 		c.loadSymbol(symbol)
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-
-			c.emit(code.OpGreaterThan)
-			return nil
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if node.Operator == "??" {
+			return c.compileNullishExpression(node)
 		}
 
 		err := c.Compile(node.Left)
@@ -191,20 +384,44 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
+		case "**":
+			c.emit(code.OpPow)
+		case "&":
+			c.emit(code.OpBitAnd)
+		case "|":
+			c.emit(code.OpBitOr)
+		case "^":
+			c.emit(code.OpBitXor)
+		case "<<":
+			c.emit(code.OpShiftLeft)
+		case ">>":
+			c.emit(code.OpShiftRight)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "<=":
+			c.emit(code.OpLessThanOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
 			c.emit(code.OpNotEqual)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return c.errorf(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -216,6 +433,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 
+	case *ast.InterpolatedString:
+		// lowered to concatenation: "a ${b} c" becomes "a" + str(b) + " c"
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Segments[0]}))
+		for i, e := range node.Expressions {
+			c.emit(code.OpGetBuiltin, object.GetBuiltinIndexByName("str"))
+			err := c.Compile(e)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpCall, 1)
+			c.emit(code.OpAdd)
+
+			if segment := node.Segments[i+1]; segment != "" {
+				c.emit(code.OpConstant, c.addConstant(&object.String{Value: segment}))
+				c.emit(code.OpAdd)
+			}
+		}
+
 	case *ast.ArrayLiteral:
 		for _, el := range node.Elements {
 			err := c.Compile(el)
@@ -252,14 +487,52 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		jumpPos := -1
+		if node.Optional {
+			jumpPos = c.skipOptional()
+		}
+
 		err = c.Compile(node.Index)
 		if err != nil {
 			return err
 		}
 
 		c.emit(code.OpIndex)
+		if node.Optional {
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
+		}
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		jumpPos := -1
+		if node.Optional {
+			jumpPos = c.skipOptional()
+		}
+
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			err := c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+		if node.Optional {
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
+		}
 
 	case *ast.CallExpression:
+		if node.Receiver {
+			node = c.moduleCall(node)
+		}
+
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
@@ -281,7 +554,33 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		for _, p := range node.Parameters {
-			c.symbolTable.Define(p.Value)
+			c.define(p.Value, false)
+		}
+		if node.Rest != nil {
+			c.define(node.Rest.Value, false)
+		}
+
+		// missing arguments are null, replace them with the defaults in the callee
+		minArity := len(node.Parameters)
+		for i, value := range node.Defaults {
+			if value == nil {
+				continue
+			}
+			if i < minArity {
+				minArity = i
+			}
+			c.emit(code.OpGetLocal, i)
+			jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+			err := c.Compile(value)
+			if err != nil {
+				return err
+			}
+			c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+			c.emit(code.OpSetLocal, i)
+		}
+		maxArity := len(node.Parameters)
+		if node.Rest != nil {
+			maxArity = -1
 		}
 
 		err := c.Compile(node.Body)
@@ -298,16 +597,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		sourceMap := c.currentSourceMap()
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.captureSymbol(s)
 		}
 
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
+			SourceMap:     sourceMap,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			MinArity:      minArity,
+			MaxArity:      maxArity,
 		}
 
 		fnIndex := c.addConstant(compiledFn)
@@ -317,17 +620,651 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+		err = c.leaveTries(0)
+		if err != nil {
+			return err
+		}
 
 		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.TryExpression:
+		err := c.compileTry(node)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bind the names of pattern, as constants if constant is set, to the value on the top of the stack. the value is
+// consumed by the last binding so the pattern never ends with an OpPop which would be taken for the value of an
+// expression statement
+func (c *Compiler) compilePattern(pattern ast.Pattern, constant bool) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		symbol := c.define(pattern.Value, constant)
+		c.storeSymbol(symbol)
+
+	case *ast.ArrayPattern:
+		for i, element := range pattern.Elements {
+			if i < len(pattern.Elements)-1 || pattern.Rest != nil {
+				c.emit(code.OpDup)
+			}
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(i)}))
+			c.emit(code.OpIndex)
+			err := c.compileBindingElement(element, constant)
+			if err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			symbol := c.define(pattern.Rest.Value, constant)
+			c.emit(code.OpRest, len(pattern.Elements))
+			c.storeSymbol(symbol)
+		}
+
+	case *ast.HashPattern:
+		for i, pair := range pattern.Pairs {
+			if i < len(pattern.Pairs)-1 {
+				c.emit(code.OpDup)
+			}
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: pair.Key.Value}))
+			c.emit(code.OpIndex)
+			err := c.compileBindingElement(pair.Value, constant)
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return c.errorf(pattern, "unknown pattern %s", pattern.String())
+	}
+
+	return nil
+}
+
+// return the identifiers bound by a let statement
+func boundNames(node *ast.LetStatement) []*ast.Identifier {
+	if node.Pattern == nil {
+		return []*ast.Identifier{node.Name}
+	}
+	return patternNames(node.Pattern)
+}
+
+// return the identifiers bound by a pattern
+func patternNames(pattern ast.Pattern) []*ast.Identifier {
+	var names []*ast.Identifier
+	var collect func(pattern ast.Pattern)
+	collect = func(pattern ast.Pattern) {
+		switch pattern := pattern.(type) {
+		case *ast.Identifier:
+			names = append(names, pattern)
+		case *ast.ArrayPattern:
+			for _, element := range pattern.Elements {
+				collect(element.Target)
+			}
+			if pattern.Rest != nil {
+				names = append(names, pattern.Rest)
+			}
+		case *ast.HashPattern:
+			for _, pair := range pattern.Pairs {
+				collect(pair.Value.Target)
+			}
+		}
+	}
+	collect(pattern)
+	return names
+}
+
+// bind the value on the top of the stack to the target of element, the default replaces a null value
+func (c *Compiler) compileBindingElement(element *ast.BindingElement, constant bool) error {
+	if element.Default != nil {
+		jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+		err := c.Compile(element.Default)
+		if err != nil {
+			return err
+		}
+		c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+	}
+	return c.compilePattern(element.Target, constant)
+}
+
+// compile a match expression to a chain of arms, each arm jumps to the next one as soon as a test of its
+// pattern or its guard fails and leaves the value of its body on the stack otherwise, null if no arm matches
+func (c *Compiler) compileMatch(node *ast.MatchExpression) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+	subject := c.define(fmt.Sprintf("$match%d", c.matchDepth), false)
+	c.storeSymbol(subject)
+
+	c.matchDepth++
+	defer func() { c.matchDepth-- }()
+
+	endJumps := []int{}
+	for _, arm := range node.Arms {
+		nextJumps, err := c.compilePatternTest(arm.Pattern, subject, nil)
+		if err != nil {
+			return err
+		}
+		if arm.Guard != nil {
+			err := c.compileGuard(arm, subject)
+			if err != nil {
+				return err
+			}
+			nextJumps = append(nextJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		// the names are bound once the pattern and the guard matched, a failed arm leaves the variables as they were
+		err = c.compilePatternBindings(arm.Pattern, subject, nil)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(arm.Body)
+		if err != nil {
+			return err
+		}
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		nextArm := len(c.currentInstructions())
+		for _, pos := range nextJumps {
+			c.changeOperand(pos, nextArm)
+		}
+	}
+	c.emit(code.OpNull)
+
+	end := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, end)
+	}
+	return nil
+}
+
+// compile the guard of an arm with the names of its pattern bound to hidden variables shadowing them
+func (c *Compiler) compileGuard(arm *ast.MatchArm, subject Symbol) error {
+	store := c.symbolTable.store
+	shadowed := map[string]Symbol{}
+	for _, name := range patternNames(arm.Pattern) {
+		if _, ok := shadowed[name.Value]; ok || name.Value == "_" {
+			continue
+		}
+		shadowed[name.Value] = store[name.Value]
+		hidden := c.define(fmt.Sprintf("$guard%d.%s", c.matchDepth-1, name.Value), false)
+		store[name.Value] = hidden
+	}
+	defer func() {
+		for name, symbol := range shadowed {
+			if symbol.Name == "" {
+				delete(store, name)
+			} else {
+				store[name] = symbol
+			}
+		}
+	}()
+
+	err := c.compilePatternBindings(arm.Pattern, subject, nil)
+	if err != nil {
+		return err
+	}
+	return c.Compile(arm.Guard)
+}
+
+// push the part of the subject at path, a list of array indexes and hash keys
+func (c *Compiler) loadPath(subject Symbol, path []object.Object) {
+	c.loadSymbol(subject)
+	for _, key := range path {
+		c.emit(code.OpConstant, c.addConstant(key))
+		c.emit(code.OpIndex)
+	}
+}
+
+// extend path without sharing the backing array with other extensions of it
+func extendPath(path []object.Object, key object.Object) []object.Object {
+	return append(path[:len(path):len(path)], key)
+}
+
+// test whether the part of the subject at path matches pattern, it returns the positions of the jumps taken
+// when a test fails. the shape of a value is tested before its elements so indexing it never fails
+func (c *Compiler) compilePatternTest(pattern ast.Pattern, subject Symbol, path []object.Object) ([]int, error) {
+	jumps := []int{}
+
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		// an identifier matches anything
+
+	case *ast.LiteralPattern:
+		c.loadPath(subject, path)
+		err := c.Compile(pattern.Value)
+		if err != nil {
+			return nil, err
+		}
+		c.emit(code.OpEqual)
+		jumps = append(jumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+	case *ast.ArrayPattern:
+		hasRest := 0
+		if pattern.Rest != nil {
+			hasRest = 1
+		}
+		c.loadPath(subject, path)
+		c.emit(code.OpMatchArray, len(pattern.Elements), hasRest)
+		jumps = append(jumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+		for i, element := range pattern.Elements {
+			elementJumps, err := c.compilePatternTest(element.Target, subject, extendPath(path, &object.Integer{Value: int64(i)}))
+			if err != nil {
+				return nil, err
+			}
+			jumps = append(jumps, elementJumps...)
+		}
+
+	case *ast.HashPattern:
+		c.loadPath(subject, path)
+		for _, pair := range pattern.Pairs {
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: pair.Key.Value}))
+		}
+		c.emit(code.OpMatchHash, len(pattern.Pairs))
+		jumps = append(jumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+		for _, pair := range pattern.Pairs {
+			pairJumps, err := c.compilePatternTest(pair.Value.Target, subject, extendPath(path, &object.String{Value: pair.Key.Value}))
+			if err != nil {
+				return nil, err
+			}
+			jumps = append(jumps, pairJumps...)
+		}
+
+	default:
+		return nil, c.errorf(pattern, "unknown pattern %s", pattern.String())
+	}
+
+	return jumps, nil
+}
+
+// bind the names of a pattern which matched the part of the subject at path, _ matches anything without binding
+func (c *Compiler) compilePatternBindings(pattern ast.Pattern, subject Symbol, path []object.Object) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			symbol := c.define(pattern.Value, false)
+			c.loadPath(subject, path)
+			c.storeSymbol(symbol)
+		}
+
+	case *ast.ArrayPattern:
+		for i, element := range pattern.Elements {
+			err := c.compilePatternBindings(element.Target, subject, extendPath(path, &object.Integer{Value: int64(i)}))
+			if err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			symbol := c.define(pattern.Rest.Value, false)
+			c.loadPath(subject, path)
+			c.emit(code.OpRest, len(pattern.Elements))
+			c.storeSymbol(symbol)
+		}
+
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			err := c.compilePatternBindings(pair.Value.Target, subject, extendPath(path, &object.String{Value: pair.Key.Value}))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+var compoundOperators = map[string]code.Opcode{
+	"+=": code.OpAdd,
+	"-=": code.OpSub,
+	"*=": code.OpMul,
+	"/=": code.OpDiv,
+}
+
+// return module["f"](a) for module.f(a), parsed as f(module, a), when module is the alias of an imported module and
+// the call itself otherwise
+func (c *Compiler) moduleCall(node *ast.CallExpression) *ast.CallExpression {
+	function, ok := node.Function.(*ast.Identifier)
+	if !ok {
+		return node
+	}
+	receiver, ok := node.Arguments[0].(*ast.Identifier)
+	if !ok {
+		return node
+	}
+	if symbol, ok := c.symbolTable.Resolve(receiver.Value); !ok || !symbol.Module {
+		return node
+	}
+
+	call := *node
+	call.Function = &ast.IndexExpression{
+		Token:    function.Token,
+		Left:     receiver,
+		Index:    &ast.StringLiteral{Token: function.Token, Value: function.Value},
+		EndToken: function.Token,
+	}
+	call.Arguments = node.Arguments[1:]
+	call.Receiver = false
+	return &call
+}
+
+// a?.b skips the rest of the expression and leaves null when a is null, return the position of the jump to
+// patch with the end of the expression
+func (c *Compiler) skipOptional() int {
+	jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+	c.emit(code.OpNull)
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+	return jumpPos
+}
+
+// compile an assignment to a variable or an index, leaving the assigned value on the stack
+func (c *Compiler) compileAssignment(node *ast.AssignExpression) error {
+	op, compound := compoundOperators[node.Operator]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			return c.errorf(target, "undefined variable %s", target.Value)
+		}
+		switch symbol.Scope {
+		case BuiltinScope:
+			return c.errorf(target, "cannot assign to built-in function %s", target.Value)
+		case FunctionScope:
+			return c.errorf(target, "cannot assign to function %s", target.Value)
+		}
+
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpression:
+		err := c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(target.Index)
+		if err != nil {
+			return err
+		}
+		if compound {
+			// keep the object and the index for OpSetIndex and read the current value with the copies
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return c.errorf(node, "cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// store the stack depth at the start of a loop in a hidden variable
+func (c *Compiler) saveStackDepth() Symbol {
+	depth := c.define(fmt.Sprintf("$depth%d", len(c.scopes[c.scopeIndex].loops)), false)
+	c.emit(code.OpStackDepth)
+	c.storeSymbol(depth)
+	return depth
+}
+
+// compile the body of a loop starting at start followed by the jump back to it, and patch the jump at exitPos and
+// the breaks out of it. like in the evaluator, the value of a loop is null
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start int, depth Symbol, exitPos int) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{start: start, depth: depth})
+	err := c.Compile(body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	// compiling the body may have grown c.scopes
+	scope = &c.scopes[c.scopeIndex]
+	l := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compile a try expression, leaving the value of the try block or the catch block on the stack
+//
+//	OpTry catch             OpTryFinally rethrow without a catch block
+//	<block>
+//	OpEndTry
+//	OpJump finally
+//	catch:                  the raised value is on the stack
+//	OpTryFinally rethrow    only with a finally block
+//	<catch block>
+//	OpEndTry                only with a finally block
+//	finally:
+//	<finally block>
+//	OpJump end
+//	rethrow:                the raised error is on the stack, also where catch jumps to without a catch block
+//	<finally block>
+//	OpThrow
+//	end:
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	t := &tryBlock{finally: node.Finally, handler: true, loopDepth: len(c.scopes[c.scopeIndex].loops)}
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, t)
+
+	tryOp := code.OpTry
+	if node.Catch == nil {
+		tryOp = code.OpTryFinally
+	}
+	tryPos := c.emit(tryOp, 9999)
+	err := c.compileBlockValue(node.Block)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+
+	rethrowTryPos := -1
+	if node.Catch != nil {
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(tryPos, len(c.currentInstructions()))
+
+		// errors raised by the catch block still run the finally block
+		t.handler = node.Finally != nil
+		if t.handler {
+			rethrowTryPos = c.emit(code.OpTryFinally, 9999)
+		}
+		if node.Param != nil {
+			symbol := c.define(node.Param.Value, false)
+			c.storeSymbol(symbol)
+		} else {
+			c.emit(code.OpPop)
+		}
+		err := c.compileBlockValue(node.Catch)
+		if err != nil {
+			return err
+		}
+		if t.handler {
+			c.emit(code.OpEndTry)
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	} else {
+		rethrowTryPos = tryPos
+	}
+
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+
+	if node.Finally == nil {
+		return nil
+	}
+	err = c.Compile(node.Finally)
+	if err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(rethrowTryPos, len(c.currentInstructions()))
+	err = c.Compile(node.Finally)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compile a block leaving its value on the stack, null if it does not end with an expression
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// remove the handlers and run the finally blocks of the try expressions being left by a jump out of them,
+// the ones inside the innermost loopDepth loops of the scope
+func (c *Compiler) leaveTries(loopDepth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= 0 && tries[i].loopDepth >= loopDepth; i-- {
+		// a jump out of the finally block only leaves the enclosing try expressions
+		c.scopes[c.scopeIndex].tries = tries[:i]
+
+		if tries[i].handler {
+			c.emit(code.OpEndTry)
+		}
+		if tries[i].finally != nil {
+			err := c.Compile(tries[i].finally)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compile a ?? b so b is only evaluated when a is null
+func (c *Compiler) compileNullishExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compile && and || so the right operand is only evaluated when it decides the result
+//
+// the result is always a boolean, the right operand is converted with a double OpBang
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if node.Operator == "||" {
+		// left is truthy, so is the result
+		c.emit(code.OpTrue)
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpBang)
+		c.emit(code.OpBang)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	// left is falsy, so is the result
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	c.emit(code.OpFalse)
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
+		SourceMap:    c.currentSourceMap(),
+		Constants:    c.constants,
+	}
+}
+
+// Module returns the program compiled so far as a module named name, ending with a return to its importer
+func (c *Compiler) Module(name string) *object.CompiledModule {
+	c.emit(code.OpEndModule)
+
+	return &object.CompiledModule{
+		Name:         name,
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.currentSourceMap(),
 		Constants:    c.constants,
+		NumGlobals:   c.symbolTable.numDefinitions,
+		Exports:      c.exports,
 	}
 }
 
@@ -335,9 +1272,23 @@ func (c *Compiler) currentInstructions() code.Instructions {
 	return c.scopes[c.scopeIndex].instructions
 }
 
+func (c *Compiler) currentSourceMap() code.SourceMap {
+	return c.scopes[c.scopeIndex].sourceMap
+}
+
+// return the innermost loop of the current scope, or nil if there is none
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
 func (c *Compiler) enterScope() {
 	scope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.SourceMap{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -374,6 +1325,7 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 func (c *Compiler) addInstruction(ins []byte) int {
 	posNewInstruction := len(c.currentInstructions())
 	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	c.scopes[c.scopeIndex].sourceMap[posNewInstruction] = c.position
 	return posNewInstruction
 }
 
@@ -410,10 +1362,18 @@ func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
 	}
 }
 
+// replace the first operand of the instruction at opPos, the others are kept
 func (c *Compiler) changeOperand(opPos int, operand int) {
-	op := code.Opcode(c.currentInstructions()[opPos])
-	newInstruction := code.Make(op, operand)
-	c.replaceInstruction(opPos, newInstruction)
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[opPos])
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		panic(err)
+	}
+
+	operands, _ := code.ReadOperands(def, ins[opPos+1:])
+	operands[0] = operand
+	c.replaceInstruction(opPos, code.Make(op, operands...))
 }
 
 func (c *Compiler) replaceLastPopWithReturn() {
@@ -423,6 +1383,30 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// emit the instruction storing the value on the top of the stack to a global or local symbol
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// load s to be captured by a closure, locals and free variables are captured as cells so that assignments are shared
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -437,3 +1421,16 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpCurrentClosure)
 	}
 }
+
+// define name in the current scope, as a constant if constant is set
+func (c *Compiler) define(name string, constant bool) Symbol {
+	if constant {
+		return c.symbolTable.DefineConst(name)
+	}
+	return c.symbolTable.Define(name)
+}
+
+// return an error prefixed with the position of the node
+func (c *Compiler) errorf(node ast.Node, format string, a ...interface{}) error {
+	return &Error{Pos: node.Pos(), Message: fmt.Sprintf(format, a...)}
+}
@@ -1,6 +1,7 @@
 package compiler
 
 import (
+	"fmt"
 	"monkey/ast"
 	"monkey/code"
 	"monkey/lexer"
@@ -78,6 +79,73 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 % 2 ** 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPow),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 & 2 | 3 ^ 4",
+			expectedConstants: []interface{}{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 << 2 >> ~3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShiftLeft),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpShiftRight),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1.5 + 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-2.5e3",
+			expectedConstants: []interface{}{2500.0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -115,11 +183,31 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []interface{}{2, 1},
+			expectedConstants: []interface{}{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThanOrEqual),
 				code.Make(code.OpPop),
 			},
 		},
@@ -179,6 +267,59 @@ func TestBooleanExpressions(t *testing.T) {
 	}
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpBang),
+				// 0006
+				code.Make(code.OpBang),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpFalse),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJump, 11),
+				// 0008
+				code.Make(code.OpFalse),
+				// 0009
+				code.Make(code.OpBang),
+				// 0010
+				code.Make(code.OpBang),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -220,6 +361,321 @@ func TestConditionals(t *testing.T) {
 	}
 }
 
+func TestWhileLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { break; continue; } 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpStackDepth),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 25),
+				// 0008
+				code.Make(code.OpGetGlobal, 0),
+				// 0011
+				code.Make(code.OpUnwind),
+				// 0012
+				code.Make(code.OpJump, 25),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpUnwind),
+				// 0019
+				code.Make(code.OpJump, 4),
+				// 0022
+				code.Make(code.OpJump, 4),
+				// 0025
+				code.Make(code.OpNull),
+				// 0026
+				code.Make(code.OpPop),
+				// 0027
+				code.Make(code.OpConstant, 0),
+				// 0030
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let i = 0; while (i) { let i = 1; }",
+			expectedConstants: []interface{}{0, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpStackDepth),
+				// 0007
+				code.Make(code.OpSetGlobal, 1),
+				// 0010
+				code.Make(code.OpGetGlobal, 0),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 25),
+				// 0016
+				code.Make(code.OpConstant, 1),
+				// 0019
+				code.Make(code.OpSetGlobal, 0),
+				// 0022
+				code.Make(code.OpJump, 10),
+				// 0025
+				code.Make(code.OpNull),
+				// 0026
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "for (k, v in [1]) { break; }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIter),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpSetGlobal, 1),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpSetGlobal, 2),
+				// 0018
+				code.Make(code.OpStackDepth),
+				// 0019
+				code.Make(code.OpSetGlobal, 3),
+				// 0022
+				code.Make(code.OpGetGlobal, 0),
+				// 0025
+				code.Make(code.OpIterNext, 45, 2),
+				// 0029
+				code.Make(code.OpSetGlobal, 2),
+				// 0032
+				code.Make(code.OpSetGlobal, 1),
+				// 0035
+				code.Make(code.OpGetGlobal, 3),
+				// 0038
+				code.Make(code.OpUnwind),
+				// 0039
+				code.Make(code.OpJump, 45),
+				// 0042
+				code.Make(code.OpJump, 22),
+				// 0045
+				code.Make(code.OpNull),
+				// 0046
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(xs) { for (x in xs) { x } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpIter),
+					// 0003
+					code.Make(code.OpSetLocal, 1),
+					// 0005
+					code.Make(code.OpNull),
+					// 0006
+					code.Make(code.OpSetLocal, 2),
+					// 0008
+					code.Make(code.OpStackDepth),
+					// 0009
+					code.Make(code.OpSetLocal, 3),
+					// 0011
+					code.Make(code.OpGetLocal, 1),
+					// 0013
+					code.Make(code.OpIterNext, 25, 1),
+					// 0017
+					code.Make(code.OpSetLocal, 2),
+					// 0019
+					code.Make(code.OpGetLocal, 2),
+					// 0021
+					code.Make(code.OpPop),
+					// 0022
+					code.Make(code.OpJump, 11),
+					// 0025
+					code.Make(code.OpNull),
+					// 0026
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x += 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] = 2; a[0] *= 3;",
+			expectedConstants: []interface{}{1, 0, 2, 0, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpDup2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let x = 1; fn() { x -= 1 } }",
+			expectedConstants: []interface{}{
+				1,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSub),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"x = 1", "1:1: undefined variable x"},
+		{"len = 1", "1:1: cannot assign to built-in function len"},
+		{"let f = fn() { f = 1 };", "1:16: cannot assign to function f"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		require.NotNil(t, err, "expected compiler error")
+		require.Equal(t, tt.expectedError, err.Error())
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "const LIMIT = 100; LIMIT",
+			expectedConstants: []interface{}{100},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let x = 1; const x = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestConstErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"const x = 1; x = 2", "1:14: cannot assign to constant x"},
+		{"const x = 1; x += 2", "1:14: cannot assign to constant x"},
+		{"const x = 1; const x = 2;", "1:20: cannot redefine constant x"},
+		{"const x = 1; let x = 2;", "1:18: cannot redefine constant x"},
+		{"const [a, ...b] = [1]; b = 2", "1:24: cannot assign to constant b"},
+		{"const {a} = {}; let [a] = [];", "1:22: cannot redefine constant a"},
+		{"const x = 1; fn() { let x = 2; }", "1:25: cannot redefine constant x"},
+		{"const x = 1; fn(x) { x }", "1:17: cannot redefine constant x"},
+		{"const x = 1; fn(...x) { x }", "1:20: cannot redefine constant x"},
+		{"fn() { const x = 1; fn() { x = 2 } }", "1:28: cannot assign to constant x"},
+		{"const x = 1; for (x in []) {}", "1:19: cannot redefine constant x"},
+		{"const e = 1; try {} catch (e) {}", "1:28: cannot redefine constant e"},
+		{"const x = 1; match (2) { x => x }", "1:26: cannot redefine constant x"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		require.NotNil(t, err, "expected compiler error for %s", tt.input)
+		require.Equal(t, tt.expectedError, err.Error())
+	}
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -268,6 +724,230 @@ func TestGlobalLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let [a, b = 2, ...c] = [];",
+			expectedConstants: []interface{}{0, 1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpArray, 0),
+				// 0003
+				code.Make(code.OpDup),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpIndex),
+				// 0008
+				code.Make(code.OpSetGlobal, 0),
+				// 0011
+				code.Make(code.OpDup),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpIndex),
+				// 0016
+				code.Make(code.OpJumpNotNull, 22),
+				// 0019
+				code.Make(code.OpConstant, 2),
+				// 0022
+				code.Make(code.OpSetGlobal, 1),
+				// 0025
+				code.Make(code.OpRest, 2),
+				// 0028
+				code.Make(code.OpSetGlobal, 2),
+			},
+		},
+		{
+			input: "fn(user) { let {name, address: [city]} = user; }",
+			expectedConstants: []interface{}{
+				"name",
+				"address",
+				0,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpDup),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpIndex),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpIndex),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpIndex),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "try { throw 1 } catch (e) { e }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 12),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpThrow),
+				// 0007
+				code.Make(code.OpNull),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 18),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "try { 1 } finally { 2 }",
+			expectedConstants: []interface{}{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTryFinally, 14),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpEndTry),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpPop),
+				// 0011
+				code.Make(code.OpJump, 19),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpThrow),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match (1) { [a] if a => a, _ => 2 }",
+			expectedConstants: []interface{}{1, 0, 0, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpMatchArray, 1, 0),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 48),
+				// 0016, the guard sees a in a hidden variable
+				code.Make(code.OpGetGlobal, 0),
+				// 0019
+				code.Make(code.OpConstant, 1),
+				// 0022
+				code.Make(code.OpIndex),
+				// 0023
+				code.Make(code.OpSetGlobal, 1),
+				// 0026
+				code.Make(code.OpGetGlobal, 1),
+				// 0029
+				code.Make(code.OpJumpNotTruthy, 48),
+				// 0032, a is bound once the guard passed
+				code.Make(code.OpGetGlobal, 0),
+				// 0035
+				code.Make(code.OpConstant, 2),
+				// 0038
+				code.Make(code.OpIndex),
+				// 0039
+				code.Make(code.OpSetGlobal, 2),
+				// 0042
+				code.Make(code.OpGetGlobal, 2),
+				// 0045
+				code.Make(code.OpJump, 55),
+				// 0048
+				code.Make(code.OpConstant, 3),
+				// 0051
+				code.Make(code.OpJump, 55),
+				// 0054
+				code.Make(code.OpNull),
+				// 0055
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let x = {}; match (x) { {"k": "v"} => 1 }`,
+			expectedConstants: []interface{}{"k", "k", "v", 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpSetGlobal, 1),
+				// 0012
+				code.Make(code.OpGetGlobal, 1),
+				// 0015
+				code.Make(code.OpConstant, 0),
+				// 0018
+				code.Make(code.OpMatchHash, 1),
+				// 0021
+				code.Make(code.OpJumpNotTruthy, 44),
+				// 0024
+				code.Make(code.OpGetGlobal, 1),
+				// 0027
+				code.Make(code.OpConstant, 1),
+				// 0030
+				code.Make(code.OpIndex),
+				// 0031
+				code.Make(code.OpConstant, 2),
+				// 0034
+				code.Make(code.OpEqual),
+				// 0035
+				code.Make(code.OpJumpNotTruthy, 44),
+				// 0038
+				code.Make(code.OpConstant, 3),
+				// 0041
+				code.Make(code.OpJump, 45),
+				// 0044
+				code.Make(code.OpNull),
+				// 0045
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
 func TestStringExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -295,6 +975,49 @@ func TestStringExpressions(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	strIndex := object.GetBuiltinIndexByName("str")
+
+	tests := []compilerTestCase{
+		{
+			input:             `"a ${1} b ${2}"`,
+			expectedConstants: []interface{}{"a ", 1, " b ", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpGetBuiltin, strIndex),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpGetBuiltin, strIndex),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let str = 1; "${str}"`,
+			expectedConstants: []interface{}{1, ""},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGetBuiltin, strIndex),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -418,6 +1141,65 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "[1, 2][1:]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"abc"[:-1]`,
+			expectedConstants: []interface{}{"abc", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{}.a",
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{}?.a ?? 1",
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpJumpNotNull, 10),
+				// 0006
+				code.Make(code.OpNull),
+				// 0007
+				code.Make(code.OpJump, 14),
+				// 0010
+				code.Make(code.OpConstant, 0),
+				// 0013
+				code.Make(code.OpIndex),
+				// 0014
+				code.Make(code.OpJumpNotNull, 20),
+				// 0017
+				code.Make(code.OpConstant, 1),
+				// 0020
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -497,6 +1279,72 @@ func TestFunctions(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 10) { a + b }",
+			expectedConstants: []interface{}{
+				10,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 1),
+					// 0002
+					code.Make(code.OpJumpNotNull, 8),
+					// 0005
+					code.Make(code.OpConstant, 0),
+					// 0008
+					code.Make(code.OpSetLocal, 1),
+					// 0010
+					code.Make(code.OpGetLocal, 0),
+					// 0012
+					code.Make(code.OpGetLocal, 1),
+					// 0014
+					code.Make(code.OpAdd),
+					// 0015
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runCompilerTests(t, tt)
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input                 string
+		expectedNumParameters int
+		expectedNumLocals     int
+		expectedMinArity      int
+		expectedMaxArity      int
+	}{
+		{"fn() { }", 0, 0, 0, 0},
+		{"fn(a, b) { }", 2, 2, 2, 2},
+		{"fn(a, b = 1, c = 2) { }", 3, 3, 1, 3},
+		{"fn(a, ...rest) { let x = 1; }", 1, 3, 1, -1},
+		{"fn(a = 1, ...rest) { }", 1, 2, 0, -1},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		require.Nil(t, err, "compiler error")
+
+		fn, ok := compiler.Bytecode().Constants[len(compiler.Bytecode().Constants)-1].(*object.CompiledFunction)
+		require.True(t, ok, "constant is not CompiledFunction")
+		require.Equal(t, tt.expectedNumParameters, fn.NumParameters, "wrong NumParameters of %s", tt.input)
+		require.Equal(t, tt.expectedNumLocals, fn.NumLocals, "wrong NumLocals of %s", tt.input)
+		require.Equal(t, tt.expectedMinArity, fn.MinArity, "wrong MinArity of %s", tt.input)
+		require.Equal(t, tt.expectedMaxArity, fn.MaxArity, "wrong MaxArity of %s", tt.input)
+	}
+}
+
 func TestFunctionCalls(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -748,7 +1596,7 @@ func TestClosure(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -778,13 +1626,13 @@ func TestClosure(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -872,6 +1720,79 @@ func TestRecursiveFunctions(t *testing.T) {
 	}
 }
 
+func TestImportStatements(t *testing.T) {
+	strings := &object.CompiledModule{Name: "strings.mk"}
+	compiler := New()
+	compiler.SetImporter(testImporter{"strings.mk": strings})
+
+	err := compiler.Compile(parse(`import "strings.mk" as str; str.upper`))
+	require.NoError(t, err)
+
+	bytecode := compiler.Bytecode()
+	testInstructions(t, []code.Instructions{
+		code.Make(code.OpImport, 0),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpIndex),
+		code.Make(code.OpPop),
+	}, bytecode.Instructions)
+	require.Len(t, bytecode.Constants, 2)
+	require.Same(t, strings, bytecode.Constants[0])
+	testStringObject(t, "upper", bytecode.Constants[1])
+
+	err = compiler.Compile(parse(`import "missing.mk" as m;`))
+	require.EqualError(t, err, "1:1: module missing.mk not found")
+
+	err = New().Compile(parse(`import "strings.mk" as str;`))
+	require.EqualError(t, err, `1:1: cannot import "strings.mk": modules are not available`)
+}
+
+func TestModules(t *testing.T) {
+	compiler := New()
+	err := compiler.Compile(parse("let a = 1; export let [b, {c}] = [2, {}]; export let d = fn() { a };"))
+	require.NoError(t, err)
+
+	module := compiler.Module("m.mk")
+	require.Equal(t, "m.mk", module.Name)
+	require.Equal(t, map[string]int{"b": 1, "c": 2, "d": 3}, module.Exports)
+	require.Equal(t, 4, module.NumGlobals)
+	require.Equal(t, code.Instructions(code.Make(code.OpEndModule)), module.Instructions[len(module.Instructions)-1:])
+	require.Len(t, module.Constants, len(compiler.Bytecode().Constants))
+}
+
+func TestCompilerErrorPositions(t *testing.T) {
+	program := parse("let a = 1;\nlet b = fn() {\n  a + c\n};")
+	compiler := New()
+	err := compiler.Compile(program)
+	require.NotNil(t, err, "expected compiler error")
+	require.Equal(t, "3:7: undefined variable c", err.Error())
+}
+
+func TestSourceMap(t *testing.T) {
+	program := parse("1;\nfn() { 2 + 3 }")
+	compiler := New()
+	err := compiler.Compile(program)
+	require.Nil(t, err)
+
+	bytecode := compiler.Bytecode()
+	// OpConstant 0, OpPop, OpClosure 3 0, OpPop
+	require.Equal(t, "1:1", bytecode.SourceMap[0].String())
+	require.Equal(t, "1:1", bytecode.SourceMap[3].String())
+	require.Equal(t, "2:1", bytecode.SourceMap[4].String())
+
+	fn, ok := bytecode.Constants[3].(*object.CompiledFunction)
+	require.True(t, ok, "constant is not a function")
+	// OpConstant 0, OpConstant 1, OpAdd, OpReturnValue
+	require.Equal(t, "2:8", fn.SourceMap[0].String())
+	require.Equal(t, "2:12", fn.SourceMap[3].String())
+	require.Equal(t, "2:8", fn.SourceMap[6].String())
+
+	pos, ok := fn.SourceMap.Lookup(4)
+	require.True(t, ok)
+	require.Equal(t, "2:12", pos.String())
+}
+
 // Helper functions
 func runCompilerTests(t *testing.T, tt compilerTestCase) {
 	t.Helper()
@@ -908,6 +1829,8 @@ func testConstants(
 		switch constant := constant.(type) {
 		case int:
 			testIntegerObject(t, int64(constant), actual[i])
+		case float64:
+			testFloatObject(t, constant, actual[i])
 		case string:
 			testStringObject(t, constant, actual[i])
 		case []code.Instructions:
@@ -924,12 +1847,29 @@ func testIntegerObject(t *testing.T, expected int64, actual object.Object) {
 	require.Equal(t, expected, result.Value, "object has wrong value")
 }
 
+func testFloatObject(t *testing.T, expected float64, actual object.Object) {
+	result, ok := actual.(*object.Float)
+	require.True(t, ok)
+	require.Equal(t, expected, result.Value, "object has wrong value")
+}
+
 func testStringObject(t *testing.T, expected string, actual object.Object) {
 	result, ok := actual.(*object.String)
 	require.True(t, ok)
 	require.Equal(t, expected, result.Value, "object has wrong value")
 }
 
+// importer of the modules of a map by import path
+type testImporter map[string]*object.CompiledModule
+
+func (ti testImporter) Import(path string, from string) (*object.CompiledModule, error) {
+	module, ok := ti[path]
+	if !ok {
+		return nil, fmt.Errorf("module %s not found", path)
+	}
+	return module, nil
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
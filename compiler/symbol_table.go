@@ -14,6 +14,12 @@ type Symbol struct {
 	Name  string
 	Scope SymbolScope
 	Index int
+
+	// a constant can be neither assigned nor redefined
+	Const bool
+
+	// set for the alias of an imported module, alias.f(x) calls the function f of the module
+	Module bool
 }
 
 type SymbolTable struct {
@@ -35,8 +41,31 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// Define defines name in this table, redefining a name of the same table reuses its slot
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	return s.define(name, false, false)
+}
+
+// DefineConst defines name as a constant in this table, checker.CheckConstants rejects its assignments and
+// redefinitions
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	return s.define(name, true, false)
+}
+
+// DefineModule defines name as the alias of an imported module in this table
+func (s *SymbolTable) DefineModule(name string) Symbol {
+	return s.define(name, false, true)
+}
+
+func (s *SymbolTable) define(name string, constant bool, module bool) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		symbol.Const = constant
+		symbol.Module = module
+		s.store[name] = symbol
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Const: constant, Module: module}
 	if s.Outer == nil {
 		symbol.Scope = GlobalScope
 	} else {
@@ -48,6 +77,16 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// report whether the nearest definition of name, in this table or an enclosing one, is a constant
+func (s *SymbolTable) isConst(name string) bool {
+	for table := s; table != nil; table = table.Outer {
+		if symbol, ok := table.store[name]; ok {
+			return symbol.Const
+		}
+	}
+	return false
+}
+
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
 	s.store[name] = symbol
@@ -81,7 +120,7 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
-	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Const: original.Const}
 	symbol.Scope = FreeScope
 
 	s.store[original.Name] = symbol
@@ -39,6 +39,41 @@ func TestDefine(t *testing.T) {
 	require.Equal(t, expected["f"], f)
 }
 
+func TestRedefine(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+	require.Equal(t, Symbol{Name: "a", Scope: GlobalScope, Index: 0}, global.Define("a"))
+	require.Equal(t, Symbol{Name: "c", Scope: GlobalScope, Index: 2}, global.Define("c"))
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("x")
+	require.Equal(t, Symbol{Name: "x", Scope: LocalScope, Index: 0}, local.Define("x"))
+	require.Equal(t, Symbol{Name: "a", Scope: LocalScope, Index: 1}, local.Define("a"))
+}
+
+func TestDefineConst(t *testing.T) {
+	global := NewSymbolTable()
+	limit := global.DefineConst("LIMIT")
+	require.Equal(t, Symbol{Name: "LIMIT", Scope: GlobalScope, Index: 0, Const: true}, limit)
+
+	// a free constant is still a constant
+	local := NewEnclosedSymbolTable(global)
+	x := local.DefineConst("x")
+	require.Equal(t, Symbol{Name: "x", Scope: LocalScope, Index: 0, Const: true}, x)
+
+	inner := NewEnclosedSymbolTable(local)
+	free, ok := inner.Resolve("x")
+	require.True(t, ok)
+	require.Equal(t, Symbol{Name: "x", Scope: FreeScope, Index: 0, Const: true}, free)
+
+	// redefining a name reuses its slot and updates whether it is a constant
+	y := global.Define("y")
+	require.Equal(t, Symbol{Name: "y", Scope: GlobalScope, Index: 1}, y)
+	y = global.DefineConst("y")
+	require.Equal(t, Symbol{Name: "y", Scope: GlobalScope, Index: 1, Const: true}, y)
+}
+
 func TestResolveGlobal(t *testing.T) {
 	global := NewSymbolTable()
 	global.Define("a")
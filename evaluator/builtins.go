@@ -12,4 +12,6 @@ var builtins = map[string]*object.Builtin{
 	"last":  object.GetBuiltinByName("last"),
 	"rest":  object.GetBuiltinByName("rest"),
 	"push":  object.GetBuiltinByName("push"),
+	"str":   object.GetBuiltinByName("str"),
+	"range": object.GetBuiltinByName("range"),
 }
@@ -1,24 +1,43 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
+	"monkey/checker"
 	"monkey/object"
+	"strings"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Eval evaluates the node. Errors are annotated with the position of the innermost node which produced them
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	//
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.Identifier:
@@ -35,11 +54,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -51,8 +87,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env, node.Const()); err != nil {
+				return err
+			}
+		} else {
+			define(env, node.Name.Value, val, node.Const())
+		}
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.ImportStatement:
+		return newError("cannot import %s: modules are only available to compiled programs", node.Path)
 	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return quote(node.Arguments, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -77,24 +126,48 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	//
 	case *ast.BlockStatement:
 		return evalBlockStatemen(node.Statements, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ThrowStatement:
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		return &object.Error{Message: "uncaught exception: " + value.Inspect(), Value: value}
+	case *ast.MacroLiteral:
+		return newError("macro literal outside of a top-level let statement")
 	case *ast.FunctionLiteral:
 		return &object.Function{
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Env:        env,
 			Body:       node.Body,
 		}
 	//
 	case *ast.Program:
+		if err := checker.CheckConstants(node, env.IsConst); err != nil {
+			return &object.Error{Message: err.Message, Pos: err.Pos}
+		}
 		return evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
@@ -124,7 +197,7 @@ func evalBlockStatemen(statements []ast.Statement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -132,6 +205,67 @@ func evalBlockStatemen(statements []ast.Statement, env *object.Environment) obje
 	return result
 }
 
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(ws.Body, env)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			case object.BREAK_OBJ:
+				return NULL
+			}
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	iterator, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError("%s is not iterable", iterable.Type())
+	}
+
+	// the variables are null until the first iteration, an empty iterable leaves them null
+	for _, variable := range fs.Variables {
+		env.Set(variable.Value, NULL)
+	}
+
+	for {
+		key, value, ok := iterator.Next()
+		if !ok {
+			return NULL
+		}
+		if len(fs.Variables) == 1 {
+			env.Set(fs.Variables[0].Value, iterator.Single(key, value))
+		} else {
+			env.Set(fs.Variables[0].Value, key)
+			env.Set(fs.Variables[1].Value, value)
+		}
+
+		result := Eval(fs.Body, env)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			case object.BREAK_OBJ:
+				return NULL
+			}
+		}
+	}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -147,6 +281,131 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	return NULL
 }
 
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		bindings := map[string]object.Object{}
+		matched, err := matchPattern(arm.Pattern, subject, env, bindings)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		// the guard sees the bindings in an environment of its own, a failed guard leaves env as it was
+		if arm.Guard != nil {
+			guardEnv := object.NewEnclosedEnvironment(env)
+			for name, val := range bindings {
+				guardEnv.Set(name, val)
+			}
+			guard := Eval(arm.Guard, guardEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		for name, val := range bindings {
+			env.Set(name, val)
+		}
+		return Eval(arm.Body, env)
+	}
+	return NULL
+}
+
+// test whether val matches pattern and collect the values of its names in bindings, _ matches anything
+// without binding. it returns an error object or nil
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment, bindings map[string]object.Object) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern.Value] = val
+		}
+		return true, nil
+
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if isError(literal) {
+			return false, literal
+		}
+		return isTruthy(evalInfixExpression("==", val, literal)), nil
+
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) ||
+			pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return false, nil
+		}
+		for i, element := range pattern.Elements {
+			matched, err := matchPattern(element.Target, array.Elements[i], env, bindings)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			elements := append([]object.Object{}, array.Elements[len(pattern.Elements):]...)
+			bindings[pattern.Rest.Value] = &object.Array{Elements: elements}
+		}
+		return true, nil
+
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+		for _, pair := range pattern.Pairs {
+			key := &object.String{Value: pair.Key.Value}
+			hashPair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				return false, nil
+			}
+			matched, err := matchPattern(pair.Value.Target, hashPair.Value, env, bindings)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+
+	default:
+		return false, newError("unknown pattern %s", pattern.String())
+	}
+}
+
+// errors raised by the block are passed to the catch block, the finally block runs however the others are left
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		if te.Param != nil {
+			env.Set(te.Param.Value, caughtValue(err))
+		}
+		result = Eval(te.Catch, env)
+	}
+
+	if te.Finally != nil {
+		// leaving the finally block by an error, return, break or continue overrides the result
+		switch finally := Eval(te.Finally, env).(type) {
+		case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+			return finally
+		}
+	}
+	return result
+}
+
+// the value of a catch variable, the thrown value or the message of a runtime error
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	return &object.String{Value: err.Message}
+}
+
 // prefix expression
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
@@ -154,6 +413,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusOperatorExpression(right)
+	case "~":
+		return evalBitNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -173,11 +434,22 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+}
+
+func evalBitNotOperatorExpression(right object.Object) object.Object {
+	integer, ok := right.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	return &object.Integer{Value: ^integer.Value}
 }
 
 // infix expression
@@ -185,6 +457,8 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -210,7 +484,65 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d", rightVal)
+		}
+		return &object.Integer{Value: integerPow(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("shift amount must not be negative, got %d", rightVal)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << rightVal}
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// integers are promoted to float when mixed with floats
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -219,11 +551,31 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// the right operand is only evaluated when the left one does not decide the result
+func evalLogicalExpression(operator string, left object.Object, right ast.Expression, env *object.Environment) object.Object {
+	if operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	value := Eval(right, env)
+	if isError(value) {
+		return value
+	}
+	return nativeBoolToBooleanObject(isTruthy(value))
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -240,6 +592,22 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	out.WriteString(node.Segments[0])
+	for i, e := range node.Expressions {
+		value := Eval(e, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(object.ToString(value).Value)
+		out.WriteString(node.Segments[i+1])
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -250,6 +618,151 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+// bind the names of pattern to the parts of val, as constants if constant is set, it returns an error object or nil
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment, constant bool) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		define(env, pattern.Value, val, constant)
+
+	case *ast.ArrayPattern:
+		for i, element := range pattern.Elements {
+			err := bindElement(element, evalIndexExpression(val, &object.Integer{Value: int64(i)}), env, constant)
+			if err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			array, ok := val.(*object.Array)
+			if !ok {
+				return newError("rest element requires an array, got %s", val.Type())
+			}
+			elements := []object.Object{}
+			if len(pattern.Elements) < len(array.Elements) {
+				elements = append(elements, array.Elements[len(pattern.Elements):]...)
+			}
+			define(env, pattern.Rest.Value, &object.Array{Elements: elements}, constant)
+		}
+
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			err := bindElement(pair.Value, evalIndexExpression(val, &object.String{Value: pair.Key.Value}), env, constant)
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return newError("unknown pattern %s", pattern.String())
+	}
+
+	return nil
+}
+
+// bind val to the target of element, the default replaces a null value
+func bindElement(element *ast.BindingElement, val object.Object, env *object.Environment, constant bool) object.Object {
+	if isError(val) {
+		return val
+	}
+	if val == NULL && element.Default != nil {
+		val = Eval(element.Default, env)
+		if isError(val) {
+			return val
+		}
+	}
+	return bindPattern(element.Target, val, env, constant)
+}
+
+// bind val to name in env, as a constant if constant is set
+func define(env *object.Environment, name string, val object.Object, constant bool) {
+	if constant {
+		env.SetConst(name, val)
+	} else {
+		env.Set(name, val)
+	}
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	// the operator of a compound assignment without the trailing '='
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			if _, ok := builtins[target.Value]; ok {
+				return newError("cannot assign to built-in function %s", target.Value)
+			}
+			return newError("identifier not found: " + target.Value)
+		}
+
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		if operator != "" {
+			value = evalInfixExpression(operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+		env.Assign(target.Value, value)
+		return value
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		var current object.Object
+		if operator != "" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		if operator != "" {
+			value = evalInfixExpression(operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+		return evalIndexAssignment(left, index, value)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+func evalIndexAssignment(left, index, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx, ok := object.Position(index.(*object.Integer).Value, len(elements))
+		if !ok {
+			return newError("index out of range: %d (length %d)", index.(*object.Integer).Value, len(elements))
+		}
+		elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unhashable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return newError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+	return value
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exps {
@@ -266,22 +779,57 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported:%s", left.Type())
+		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
-	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	elements := array.(*object.Array).Elements
+	idx, ok := object.Position(index.(*object.Integer).Value, len(elements))
+	if !ok {
+		return NULL
+	}
+	return elements[idx]
+}
+
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := object.Position(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
 
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Optional && left == NULL {
 		return NULL
 	}
-	return arrayObject.Elements[idx]
+
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Low, node.High} {
+		if bound != nil {
+			bounds[i] = Eval(bound, env)
+			if isError(bounds[i]) {
+				return bounds[i]
+			}
+		}
+	}
+
+	slice, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+	return slice
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -328,7 +876,10 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
@@ -341,12 +892,43 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// bind the arguments to the parameters of fn, defaults are evaluated in the new environment so they can use earlier parameters
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	minArity, maxArity := len(fn.Parameters), len(fn.Parameters)
+	for i, value := range fn.Defaults {
+		if value != nil && i < minArity {
+			minArity = i
+		}
+	}
+	if fn.Rest != nil {
+		maxArity = -1
+	}
+	if len(args) < minArity || (maxArity >= 0 && len(args) > maxArity) {
+		return nil, newError("%s", object.WrongArity(minArity, maxArity, len(args)))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIndex, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIndex])
+		var arg object.Object = NULL
+		if paramIndex < len(args) {
+			arg = args[paramIndex]
+		}
+		if arg == NULL && paramIndex < len(fn.Defaults) && fn.Defaults[paramIndex] != nil {
+			arg = Eval(fn.Defaults[paramIndex], env)
+			if isError(arg) {
+				return nil, arg
+			}
+		}
+		env.Set(param.Value, arg)
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -364,6 +946,31 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+// compute base ** exp by squaring, exp must not be negative
+func integerPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// convert INTEGER or FLOAT object to float64
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -381,9 +988,14 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// report whether obj ends the evaluation of the enclosing expressions, an error or the signal of a return, break
+// or continue statement
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		switch obj.Type() {
+		case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return true
+		}
 	}
 	return false
 }
@@ -24,6 +24,24 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"5 * 2 + 10", 20},
 		{"50 / 2 * 2 + 10", 60},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+		{"2 * 3 ** 2", 18},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 << 0", 1},
+		{"let x = 8; x >> 0", 8},
+		{"1 << 2 + 1", 8},
+		{"1 | 2 ^ 3 & 5", 3},
+		{"0xDEADBEEF & 0xFFFF", 0xBEEF},
 	}
 
 	for _, tt := range tests {
@@ -32,6 +50,25 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"7 / 2.0", 3.5},
+		{"let total = 10; let count = 4; total / (count * 1.0)", 2.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, tt.expected, evaluated)
+	}
+}
+
 func TestEvalStringExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -39,6 +76,25 @@ func TestEvalStringExpression(t *testing.T) {
 	}{
 		{`"hello world!";`, "hello world!"},
 		{`"hello" + " " + "world!";`, "hello world!"},
+		{"`line1\nline2 \\n`", "line1\nline2 \\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testStringObject(t, tt.expected, evaluated)
+	}
+}
+
+func TestEvalInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain ${"string"}"`, "plain string"},
+		{`let user = {"name": "Monkey"}; let items = [1, 2]; "Hello ${user["name"]}, you have ${len(items)} items"`, "Hello Monkey, you have 2 items"},
+		{`"${1.5} ${true} ${[1, "a"]} ${if (false) {1}}"`, "1.5 true [1, a] null"},
+		{`let f = fn(x) { "<${x}>" }; "${f("${1 + 1}")}!"`, "<2>!"},
+		{`let s = ""; let f = fn(x) { s += x; 1 }; f("a") < f("b"); s`, "ab"},
 	}
 
 	for _, tt := range tests {
@@ -72,6 +128,30 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
 		{`"hello" + "world" == "helloworld"`, true},
+		{"1.5 > 1", true},
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"2.5 != 2.5", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"2 <= 1.5", false},
+		{"let a = 1; a < (a = 5)", true},
+		{"let a = 5; a <= (a = 1)", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"0 || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
 	}
 
 	for _, tt := range tests {
@@ -141,6 +221,8 @@ func TestReturnExpression(t *testing.T) {
 			return 5;
 		}
 		`, 10},
+		{"fn() { 1 + if (true) { return 5 } else { 2 } }()", 5},
+		{"fn() { [1, if (true) { return 5 }] }()", 5},
 	}
 
 	for _, tt := range tests {
@@ -169,6 +251,49 @@ func TestErrorHandling(t *testing.T) {
 		}`, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
 		{`{"name": "Monkey"}[fn(x) {x}]`, "unhashable as hash key: FUNCTION"},
+		{"x = 1", "identifier not found: x"},
+		{"let x = 0; 5 % x", "modulo by zero"},
+		{"let x = 0; 5 / x", "division by zero"},
+		{"let x = 10; x /= 0", "division by zero"},
+		{"1 << -1", "shift amount must not be negative, got -1"},
+		{"8 >> -2", "shift amount must not be negative, got -2"},
+		{"2 ** -1", "negative exponent: -1"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"1.5 % 2", "unknown operator: FLOAT % INTEGER"},
+		{"let [a, ...b] = 1", "index operator not supported: INTEGER"},
+		{`let [...b] = {"a": 1}`, "rest element requires an array, got HASH_OBJ"},
+		{"fn(a, b = 1) { a }()", "wrong number of arguments: want=1 to 2, got=0"},
+		{"fn(a, b = 1) { a }(1, 2, 3)", "wrong number of arguments: want=1 to 2, got=3"},
+		{"fn(a, b, ...c) { a }(1)", "wrong number of arguments: want at least 2, got=1"},
+		{"fn(a) { a }()", "wrong number of arguments: want=1, got=0"},
+		{"fn(a = b) { a }()", "identifier not found: b"},
+		{"len = 1", "cannot assign to built-in function len"},
+		{`import "lib.mk" as lib;`, `cannot import "lib.mk": modules are only available to compiled programs`},
+		{"let a = [1, 2]; a[2] = 3", "index out of range: 2 (length 2)"},
+		{"let a = [1, 2]; a[-3] = 3", "index out of range: -3 (length 2)"},
+		{`[1, 2]["a":]`, "slice bounds must be integers, got STRING"},
+		{"[1, 2][:1.5]", "slice bounds must be integers, got FLOAT"},
+		{"{}[1:2]", "slice operator not supported: HASH_OBJ"},
+		{"let h = {}; h[[1]] = 1", "unhashable as hash key: ARRAY_OBJ"},
+		{`"abc"[0] = "x"`, "index assignment not supported: STRING[INTEGER]"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"const x = 1; x = 2", "cannot assign to constant x"},
+		{"const x = 1; x += 2", "cannot assign to constant x"},
+		{"const x = 1; const x = 2;", "cannot redefine constant x"},
+		{"const x = 1; let x = 2;", "cannot redefine constant x"},
+		{"const [a, ...b] = [1]; b = 2", "cannot assign to constant b"},
+		{"const {a} = {}; let [a] = [];", "cannot redefine constant a"},
+		{"const x = 1; fn() { let x = 2; }()", "cannot redefine constant x"},
+		{"const x = 1; fn(x) { x }(2)", "cannot redefine constant x"},
+		{"const x = 1; fn(...x) { x }()", "cannot redefine constant x"},
+		{"fn() { const x = 1; fn() { x = 2 } }()()", "cannot assign to constant x"},
+		{"const x = 1; for (x in [1]) {}", "cannot redefine constant x"},
+		{`const e = 1; try { throw "a"; } catch (e) {}`, "cannot redefine constant e"},
+		{"const x = 1; match (2) { x => x }", "cannot redefine constant x"},
+		{"const x = 1; let f = fn() { x = 2 }; 5", "cannot assign to constant x"},
+		{"const x = 1; let f = fn(x) { x }; 5", "cannot redefine constant x"},
+		{"const x = 1; if (false) { let x = 2 }; 5", "cannot redefine constant x"},
+		{"const x = 1; while (false) { x += 1 }; 5", "cannot assign to constant x"},
 	}
 
 	for _, tt := range tests {
@@ -179,6 +304,30 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedPos     string
+		expectedInspect string
+	}{
+		{"5 + true", "1:1", "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\nlet b = -true;", "2:9", "ERROR: 2:9: unknown operator: -BOOLEAN"},
+		{"let f = fn() {\n  foobar\n};\nf()", "2:3", "ERROR: 2:3: identifier not found: foobar"},
+		{"let x = 1;\nfor (i in x) { i }", "2:1", "ERROR: 2:1: INTEGER is not iterable"},
+		{"try { throw 1 } finally { 2 }", "1:7", "ERROR: 1:7: uncaught exception: 1"},
+		{"try { 1 / 0 } finally { 2 }", "1:7", "ERROR: 1:7: division by zero"},
+		{"const x = 1;\nlet f = fn() { x = 2 };\n5", "2:16", "ERROR: 2:16: cannot assign to constant x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		require.True(t, ok, "no error object returned")
+		require.Equal(t, tt.expectedPos, errObj.Pos.String(), "wrong error position")
+		require.Equal(t, tt.expectedInspect, errObj.Inspect(), "wrong inspected error")
+	}
+}
+
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -250,8 +399,11 @@ func TestBuiltinFunction(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("한글")`, 2},
 		{`len(2)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len(range(0, 10, 3))`, 4},
+		{`range(1, 2, 0)`, "step of `range` must not be 0"},
 	}
 
 	for _, tt := range tests {
@@ -295,7 +447,9 @@ func TestArrayIndexExpressions(t *testing.T) {
 		{`let myArray = [1,2,3]; myArray[0] + myArray[1] + myArray[2]`, 6},
 		{`let myArray = [1,2,3]; let i = myArray[0]; myArray[i]`, 2},
 		{`[1,2,3][3]`, nil},
-		{`[1,2,3][-1]`, nil},
+		{`[1,2,3][-1]`, 3},
+		{`[1,2,3][-3]`, 1},
+		{`[1,2,3][-4]`, nil},
 	}
 
 	for _, tt := range tests {
@@ -310,6 +464,55 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSlicesAndStringIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[1, 2, 3, 4][5:]", []int{}},
+		{"let n = 2; [1, 2, 3, 4][n - 1:n + 1]", []int{2, 3}},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a[0]", 1},
+		{"let a = [1, 2, 3]; a[-1] = 9; a[2]", 9},
+		{`"hello"[1]`, "e"},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[5]`, nil},
+		{`"héllo"[1]`, "é"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:-2]`, "hel"},
+		{`"hello"[3:]`, "lo"},
+		{`"héllo"[1:2]`, "é"},
+		{`"hello"[4:2]`, ""},
+		{`let s = "abc"; s[s.len() - 1]`, "c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			require.True(t, ok, "object is not Array, got %T (%+v)", evaluated, evaluated)
+			require.Len(t, array.Elements, len(expected))
+			for i, element := range expected {
+				testIntegerObject(t, int64(element), array.Elements[i])
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestHashLiteral(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -389,7 +592,411 @@ func TestHashIndexExpression(t *testing.T) {
 	}
 }
 
-//
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 100000) { let i = i + 1; }; i", 100000},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; if (i == 5) { continue; } let sum = sum + i; }; sum", 50},
+		{"let i = 0; while (true) { if (i == 7) { break; } let i = i + 1; }; i", 7},
+		{`
+		let i = 0;
+		let count = 0;
+		while (i < 3) {
+			let j = 0;
+			while (true) {
+				if (j == 4) { break; }
+				let j = j + 1;
+				let count = count + 1;
+			}
+			let i = i + 1;
+		};
+		count`, 12},
+		{"let f = fn(n) { let i = 0; let total = 0; while (i < n) { let i = i + 1; let total = total + i; } total }; f(10)", 55},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 3) { return i * 2; } } }; f()", 6},
+		{"let i = 0; while (i < 5000) { i += 1; let x = 1 + if (true) { continue } else { 2 }; }; i", 5000},
+		{"let i = 0; while (true) { i += 1; let x = [1, 2, if (i == 3) { break } else { 3 }]; }; i", 3},
+		{"fn() { let i = 0; while (i < 5000) { i += 1; -if (true) { continue } else { 1 }; } i }()", 5000},
+		{"let f = fn(a, b) { a + b }; let n = 0; for (x in range(10)) { n += f(x, if (x == 4) { break } else { 1 }); }; n", 10},
+		{"let n = 0; for (x in range(5000)) { n += 1 + if (x % 2 == 0) { continue } else { x }; }; n", 6252500},
+		{`
+		let make = fn() {
+			let i = 0;
+			let fs = [];
+			while (i < 3) {
+				let x = i;
+				let fs = push(fs, fn() { x * 10 });
+				let i = i + 1;
+			}
+			fs
+		};
+		make()[2]()`, 20},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, tt.expected, testEval(tt.input))
+	}
+	testNullObject(t, testEval("while (false) { 1 }"))
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { let sum = sum + x; }; sum", 10},
+		{`let s = ""; for (k, v in {"b": 2, "a": 1, "c": 3}) { let s = s + k + str(v); }; s`, "a1b2c3"},
+		{`let s = ""; for (k in {2: "x", 1: "y", true: "z"}) { let s = s + str(k); }; s`, "true12"},
+		{`let s = ""; for (c in "한글ab") { let s = c + s; }; s`, "ba글한"},
+		{`let s = ""; for (i, c in "xyz") { let s = s + str(i) + c; }; s`, "0x1y2z"},
+		{"let sum = 0; for (i in range(100000)) { let sum = sum + i; }; sum", 4999950000},
+		{`let s = ""; for (i in range(10, 0, -3)) { let s = s + str(i) + ","; }; s`, "10,7,4,1,"},
+		{"let sum = 0; for (x in range(1, 100)) { if (x > 10) { break; } if (x == 3) { continue; } let sum = sum + x; }; sum", 52},
+		{"let count = 0; for (i in range(3)) { for (j, x in [1, 2]) { let count = count + i * x; } }; count", 9},
+		{"let total = fn(arr) { let t = 0; for (x in arr) { let t = t + x; } t }; total([1, 2, 3])", 6},
+		{"let find = fn(arr, target) { for (i, x in arr) { if (x == target) { return i; } } -1 }; find([5, 6, 7], 7)", 2},
+		{"for (x in []) {}; x", nil},
+		{"let x = 5; for (x in \"\") {}; x", nil},
+		{"fn() { for (x in []) {}; x }()", nil},
+		{"for (k, v in {}) {}; k ?? v ?? 1", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLoopValues(t *testing.T) {
+	tests := []string{
+		"let i = 0; while (i < 3) { i += 1 }",
+		"let i = 0; while (true) { i += 1; if (i == 3) { break } }",
+		"for (x in [1, 2]) { x }",
+		"for (x in [1, 2]) { break }",
+		"fn() { while (false) {} }()",
+		"if (true) { for (x in []) {} }",
+	}
+
+	for _, input := range tests {
+		testNullObject(t, testEval(input))
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = x = 5; x + y", 10},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let f = fn() { let x = 1; x += 41; x }; f()", 42},
+		{"let x = 1; let f = fn() { x = 7; }; f(); x", 7},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let make = fn() { let n = 0; [fn() { n += 1 }, fn() { n }] }; let p = make(); p[0](); p[0](); p[1]()", 2},
+		{"let f = fn() { let n = 1; let g = fn() { fn() { n *= 10 } }; g()(); g()(); n }; f()", 100},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); n += 10; inc(); n }; f()", 12},
+		{"let sum = 0; let i = 0; while (i < 5) { i += 1; sum += i; }; sum", 15},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum", 6},
+		{"let a = [1, 2, 3]; a[1] = 20; a[2] += 10; a", []int{1, 20, 13}},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`, 7},
+		{"let a = [[1, 2]]; a[0][1] *= 3; a[0][1]", 6},
+		{"let n = 0; let next = fn() { n += 1; n - 1 }; let a = [10, 20]; a[next()] += 1; [n, a[0], a[1]]", []int{1, 11, 20}},
+		{"let a = [1]; let b = a; b[0] = 5; a[0]", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			require.True(t, ok, "object is not Array. got=%T (%+v)", evaluated, evaluated)
+			require.Len(t, array.Elements, len(expected))
+			for i, e := range expected {
+				testIntegerObject(t, int64(e), array.Elements[i])
+			}
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"const LIMIT = 100; LIMIT", 100},
+		{"const LIMIT = 100; let f = fn() { LIMIT * 2 }; f()", 200},
+		{"let f = fn(n) { const double = n * 2; fn() { double + 1 } }; f(1)() + f(2)()", 8},
+		{"const [a, ...b] = [1, 2, 3]; a + len(b)", 3},
+		{"let sum = 0; for (x in [1, 2, 3]) { const y = x * 10; sum += y; }\nsum", 60},
+		{"let x = 1; const x = 2; x", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, tt.expected, testEval(tt.input))
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [head, second, ...tail] = [1, 2, 3, 4, 5]; head * 100 + second * 10 + len(tail)", 123},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [a, b, ...rest] = [1]; len(rest)", 0},
+		{"let [...all] = [1, 2]; all", []int{1, 2}},
+		{"let [a, b = 10, c = a + 100] = [1]; a + b + c", 112},
+		{"let [a = 5] = [0]; a", 0},
+		{`let {name, age} = {"name": "kim", "age": 30}; name + str(age)`, "kim30"},
+		{`let {name, age = 20} = {"name": "lee"}; age`, 20},
+		{`let {name: n, "home town": town} = {"name": "park", "home town": "seoul"}; n + town`, "parkseoul"},
+		{`let {address: {city}, tags: [first, ...others]} = {"address": {"city": "busan"}, "tags": ["a", "b", "c"]}; city + first + str(len(others))`, "busana2"},
+		{"let [[a, b], [c, d]] = [[1, 2], [3, 4]]; a * 1000 + b * 100 + c * 10 + d", 1234},
+		{`let [{x}, {x: y}] = [{"x": 1}, {"x": 2}]; x + y`, 3},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([10, 3])", 7},
+		{"let f = fn(xs) { let [first, ...rest] = xs; fn() { first + len(rest) } }; f([10, 20, 30])()", 12},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			require.True(t, ok, "object is not Array. got=%T (%+v)", evaluated, evaluated)
+			require.Len(t, array.Elements, len(expected))
+			for i, e := range expected {
+				testIntegerObject(t, int64(e), array.Elements[i])
+			}
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f()", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f(5)", 15},
+		{"let n = 0; let next = fn() { n += 1; n }; let f = fn(a = next()) { a }; f(); f(); f()", 3},
+		{"let f = fn(a, ...rest) { len(rest) }; f(1)", 0},
+		{"let f = fn(a, ...rest) { len(rest) }; f(1, 2, 3)", 2},
+		{"let f = fn(...args) { args }; f(1, 2, 3)", []int{1, 2, 3}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1)", []int{1, 2, 0}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)", []int{1, 5, 2}},
+		{"let sum = fn(...xs) { let t = 0; for (x in xs) { t += x; } t }; sum(1, 2, 3, 4)", 10},
+		{"let make = fn(base) { fn(x, step = base) { x + step } }; make(100)(1)", 101},
+		{"let f = fn(a, ...rest) { fn() { a + len(rest) } }; f(10, 1, 1)()", 12},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			require.True(t, ok, "object is not Array. got=%T (%+v)", evaluated, evaluated)
+			require.Len(t, array.Elements, len(expected))
+			for i, e := range expected {
+				testIntegerObject(t, int64(e), array.Elements[i])
+			}
+		}
+	}
+}
+
+func TestPipelineAndMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2)", 3},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3) |> sub(2)", 5},
+		{"[1, 2, 3] |> len", 3},
+		{`"abc" |> len()`, 3},
+		{"let sum = fn(xs) { let t = 0; for (x in xs) { t += x; } t }; [1, 2, 3] |> push(4) |> sum", 10},
+		{"let inc = fn(x, by = 1) { x + by }; 1.inc().inc(10)", 12},
+		{"[1, 2, 3].push(4).len()", 4},
+		{`"hello".len() + [1].len()`, 6},
+		{"let f = fn(x) { fn(y) { x * y } }; 3 |> f |> fn(g) { g(5) }", 15},
+		{"let xs = [1, 2, 3]; xs.first() + xs.last() |> fn(x) { x * 10 }", 40},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, tt.expected, testEval(tt.input))
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (7) { 1 => "one", 2 => "two", _ => "many" }`, "many"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (-1) { -1 => 10, 1 => 20 }", 10},
+		{"match (2.0) { 2 => 1, _ => 0 }", 1},
+		{"match (true) { false => 0, true => 1 }", 1},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, 2, 3]) { [] => 0, [a, b] => a + b, [a, ...rest] => a * 10 + len(rest) }", 12},
+		{"match ([]) { [a, ...rest] => 1, [] => 2 }", 2},
+		{"match ([0, 5]) { [1, x] => x, [0, x] => x * 2 }", 10},
+		{`match ({"type": "user", "name": "kim"}) { {"type": "admin"} => "admin", {"type": "user", "name": n} => n }`, "kim"},
+		{`match ({"a": 1}) { {b} => b, {} => "hash" }`, "hash"},
+		{`match ([{"p": [1, 2]}]) { [{"p": [x, y]}] => x + y }`, 3},
+		{`match ("x") { [a] => a, {a} => a, _ => 0 }`, 0},
+		{"match (5) { n if n < 0 => -1, n if n > 0 => 1, _ => 0 }", 1},
+		{"match ([3, 4]) { [a, b] if a > b => a, [a, b] => b }", 4},
+		{"match (5) { _ => { let x = 2; x * 3 } }", 6},
+		{"match (3) { 1 => 1 }", nil},
+		{"let f = fn(x) { match (x) { [a, b] => fn() { a * b }, _ => fn() { 0 } } }; f([6, 7])()", 42},
+		{"let describe = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + describe(rest) } }; describe([1, 2, 3, 4])", 10},
+		{"match (match (1) { 1 => [2] }) { [x] => match (x) { 2 => 20 } }", 20},
+		{"let x = 1; match ([2]) { [x] if false => 0, _ => x }", 1},
+		{"let x = 1; match ([2, 3]) { [x] => 0, [a, b] => x }", 1},
+		{"let f = fn() { let x = 1; match ([2]) { [x] if x > 5 => 0, _ => x } }; f()", 1},
+		{"let x = 1; match ([2]) { [x] if x == 2 => x * 10 }", 20},
+		{"let x = 1; match ([2]) { [x] if x == 2 => 0 }; x", 2},
+		{"let x = 1; match ([[5]]) { [[x]] if fn() { x }() == 5 => x }", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDotAccessAndNullCoalescing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let user = {"name": "kim", "profile": {"email": "kim@example.com"}}; user.profile.email`, "kim@example.com"},
+		{`let user = {"name": "kim"}; user.age`, nil},
+		{`let user = {"name": "kim"}; user?.profile?.email`, nil},
+		{`let user = {}.user; user?.profile?.email`, nil},
+		{`let users = [{"name": "kim"}]; users?.[0]?.name`, "kim"},
+		{`let users = [][0]; users?.[0]?.name`, nil},
+		{"let a = [][0]; a?.[1:2]", nil},
+		{"len([1, 2, 3]?.[1:])", 2},
+		{`"abc"?.[:2]`, "ab"},
+		{`let user = {"name": "kim"}; user?.profile?.email ?? "none"`, "none"},
+		{`let user = {"name": "kim"}; user.name ?? "anonymous"`, "kim"},
+		{"[][0] ?? 1", 1},
+		{"0 ?? 1", 0},
+		{"false ?? 1 ?? 2", false},
+		{"[][0] ?? {}.x ?? 3", 3},
+		{"let n = 0; let next = fn() { n += 1; n }; 5 ?? next(); n", 0},
+		{`let h = {"a": 1}; h.a = 2; h.b = h.a + 1; h.a * 10 + h.b`, 23},
+		{`let h = {"count": 1}; h.count += 4; h.count`, 5},
+		{`let inc = fn(h) { h.n + 1 }; {"n": 1}.inc()`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		case bool:
+			testBooleanObject(t, expected, evaluated)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { throw 5 } catch (e) { e * 2 }", 10},
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { throw 1 } catch { 2 }", 2},
+		{`try { throw {"code": 404} } catch (e) { e.code }`, 404},
+		{`let log = ""; try { log += "t"; throw 1 } catch (e) { log += "c" } finally { log += "f" }; log`, "tcf"},
+		{`let log = ""; let x = try { 1 } finally { log += "f" }; log + x.str()`, "f1"},
+		{`let f = fn() { throw "deep" }; let g = fn() { f() + 1 }; try { g() } catch (e) { "caught " + e }`, "caught deep"},
+		{"let f = fn() { throw 1 }; 1 + try { 5 + [1, f()][0] } catch (e) { e + 1 }", 3},
+		{"try { try { throw 1 } finally { 2 } } catch (e) { e + 10 }", 11},
+		{"try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e * 10 }", 20},
+		{`let log = ""; try { try { throw 1 } catch (e) { throw 2 } finally { log += "f" } } catch (e) { log += e.str() }; log`, "f2"},
+		{`let log = ""; let f = fn() { try { return "r" } finally { log += "f" } }; f() + log`, "rf"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; f()", 2},
+		{"let n = 0; let i = 0; while (i < 3) { i += 1; try { if (i == 2) { break } } finally { n += 1 } }\nn * 10 + i", 22},
+		{"let n = 0; let i = 0; while (i < 3) { i += 1; try { if (i == 2) { continue } n += 10 } finally { n += 1 } }\nn", 23},
+		{"let r = 0; for (x in [1, \"a\", 2]) { r += try { x * 2 } catch { 100 } }\nr", 106},
+		{"let f = fn(n) { if (n == 0) { throw \"bottom\" } f(n - 1) }; try { f(50) } catch (e) { e }", "bottom"},
+		{"try { 1(2) } catch (e) { e }", "not a function: INTEGER"},
+		{"try { [1][true] } catch (e) { e }", "index operator not supported: ARRAY_OBJ"},
+		{"try { len(1) } catch (e) { e }", "argument to `len` not supported, got INTEGER"},
+		{"let d = 0; try { 10 / d } catch (e) { e }", "division by zero"},
+		{"try { push(len(1), 2) } catch (e) { 0 }", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		}
+	}
+}
+
+func TestUncaughtExceptions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`throw "boom"`, "uncaught exception: boom"},
+		{"let f = fn() {\n  throw [1]\n}\ntry { f() } finally { 1 }", "uncaught exception: [1]"},
+		{"let f = fn() { try { throw 1 } finally { throw 2 } }; f()", "uncaught exception: 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		require.True(t, ok, "no error object returned, got %T (%+v)", evaluated, evaluated)
+		require.Equal(t, tt.expectedMessage, errObj.Message)
+	}
+}
+
 // Helper functions
 func testEval(input string) object.Object {
 	l := lexer.New(input)
@@ -405,6 +1012,12 @@ func testIntegerObject(t *testing.T, expected int64, actual object.Object) {
 	require.Equal(t, expected, result.Value, "object has wrong value")
 }
 
+func testFloatObject(t *testing.T, expected float64, actual object.Object) {
+	result, ok := actual.(*object.Float)
+	require.True(t, ok, "object is not float, %s", actual)
+	require.Equal(t, expected, result.Value, "object has wrong value")
+}
+
 func testStringObject(t *testing.T, expected string, actual object.Object) {
 	result, ok := actual.(*object.String)
 	require.True(t, ok, "object is not string, %s", actual)
@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/object"
+)
+
+// DefineMacros moves the macros defined by top-level let statements of program into env
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+	for _, statement := range program.Statements {
+		if !isMacroDefinition(statement) {
+			statements = append(statements, statement)
+			continue
+		}
+
+		let := statement.(*ast.LetStatement)
+		macro := let.Value.(*ast.MacroLiteral)
+		env.Set(let.Name.Value, &object.Macro{
+			Parameters: macro.Parameters,
+			Body:       macro.Body,
+			Env:        env,
+		})
+	}
+	program.Statements = statements
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	let, ok := node.(*ast.LetStatement)
+	if !ok || let.Name == nil {
+		return false
+	}
+	_, ok = let.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+// ExpandMacros returns program with the calls of the macros in env replaced by the nodes they return,
+// it runs before the program is evaluated or compiled
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			err = fmt.Errorf("%s: %s", call.Pos(), object.WrongArity(len(macro.Parameters), len(macro.Parameters), len(call.Arguments)))
+			return node
+		}
+		// the body is not part of the program, so it is checked on its own
+		checkErr := checker.CheckConstants(&ast.MacroLiteral{Parameters: macro.Parameters, Body: macro.Body}, macro.Env.IsConst)
+		if checkErr != nil {
+			err = checkErr
+			return node
+		}
+
+		evalEnv := object.NewEnclosedEnvironment(macro.Env)
+		for i, param := range macro.Parameters {
+			evalEnv.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+		}
+
+		result := unwrapReturnValue(Eval(macro.Body, evalEnv))
+		switch result := result.(type) {
+		case *object.Quote:
+			return result.Node
+		case *object.Error:
+			err = fmt.Errorf("%s: %s", call.Pos(), result.Message)
+			if result.Pos.IsValid() {
+				err = fmt.Errorf("%s: %s", result.Pos, result.Message)
+			}
+		default:
+			err = fmt.Errorf("%s: macro %s must return a quote", call.Pos(), call.Function.String())
+		}
+		return node
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expanded, nil
+}
+
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	DefineMacros(program, env)
+
+	require.Len(t, program.Statements, 2)
+	_, ok := env.Get("number")
+	require.False(t, ok, "number should not be defined")
+	_, ok = env.Get("function")
+	require.False(t, ok, "function should not be defined")
+
+	obj, ok := env.Get("mymacro")
+	require.True(t, ok, "macro not in environment")
+	macro, ok := obj.(*object.Macro)
+	require.True(t, ok, "object is not Macro, got %T (%+v)", obj, obj)
+	require.Len(t, macro.Parameters, 2)
+	require.Equal(t, "x", macro.Parameters[0].String())
+	require.Equal(t, "y", macro.Parameters[1].String())
+	require.Equal(t, "(x + y)", macro.Body.String())
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)) }; twice(1) * twice(2)`,
+			`((1 + 1) * (2 + 2))`,
+		},
+		{
+			`let m = macro() { quote(1) }; let f = fn() { m() }; f`,
+			`let f = fn() { 1 }; f`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(t, tt.expected)
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), expanded.String())
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`let m = macro(a) { quote(a) }; m()`, "1:32: wrong number of arguments: want=1, got=0"},
+		{`let m = macro() { 1 }; m()`, "1:24: macro m must return a quote"},
+		{`let m = macro() { missing }; m()`, "1:19: identifier not found: missing"},
+		{`let m = macro(a) { const b = 1; b = 2; quote(a) }; m(1)`, "1:33: cannot assign to constant b"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		require.EqualError(t, err, tt.expectedError)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 10, 20)`, 10},
+		{`let square = macro(x) { quote(unquote(x) * unquote(x)) }; let n = 3; square(n + 1)`, 16},
+		{`let assert = macro(cond) { quote(if (!(unquote(cond))) { "assertion failed: " + unquote(cond.str()) } else { "ok" }) }; assert(1 + 1 == 3)`, "assertion failed: QUOTE(((1 + 1) == 3))"},
+		{`let add = macro(a, b) { quote(unquote(a) + unquote(b)) }; add(1, 2) + add(3, 4)`, 10},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		require.NoError(t, err)
+
+		evaluated := Eval(expanded, object.NewEnvironment())
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, int64(expected), evaluated)
+		case string:
+			testStringObject(t, expected, evaluated)
+		}
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), "parser errors")
+	return program
+}
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+)
+
+// quote returns its argument unevaluated, except for the unquote calls in it which are replaced by
+// the nodes of their values
+func quote(args []ast.Expression, env *object.Environment) object.Object {
+	if len(args) != 1 {
+		return newError("%s", object.WrongArity(1, 1, len(args)))
+	}
+
+	var err object.Object
+	node := ast.Modify(args[0], func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isUnquoteCall(call) || err != nil {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = newError("%s", object.WrongArity(1, 1, len(call.Arguments)))
+			return node
+		}
+
+		value := Eval(call.Arguments[0], env)
+		if isError(value) {
+			err = value
+			return node
+		}
+		converted := convertObjectToASTNode(value)
+		if converted == nil {
+			err = newError("unquote: cannot convert %s to an expression", value.Type())
+			return node
+		}
+		return converted
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// the node evaluating to obj, nil if there is no literal for its type
+func convertObjectToASTNode(obj object.Object) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+
+	case *object.Float:
+		return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: obj.Inspect()}, Value: obj.Value}
+
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value}, Value: obj.Value}
+
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+		}
+		return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.L_BRACKET, Literal: "["}}
+		for _, element := range obj.Elements {
+			node, ok := convertObjectToASTNode(element).(ast.Expression)
+			if !ok {
+				return nil
+			}
+			array.Elements = append(array.Elements, node)
+		}
+		return array
+
+	case *object.Quote:
+		return obj.Node
+
+	default:
+		return nil
+	}
+}
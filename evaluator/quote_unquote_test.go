@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(fn(x) { x * 2 })`, `fn(x)(x * 2)`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		require.True(t, ok, "expected *object.Quote, got %T (%+v)", evaluated, evaluated)
+		require.NotNil(t, quote.Node)
+		require.Equal(t, tt.expected, quote.Node.String())
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote(1.5) * unquote(-2))`, `(1.5 * -2)`},
+		{`quote(unquote("a" + "b"))`, `"ab"`},
+		{`quote(unquote([1, [2]]))`, `[1, [2]]`},
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		require.True(t, ok, "expected *object.Quote, got %T (%+v)", evaluated, evaluated)
+		require.NotNil(t, quote.Node)
+		require.Equal(t, tt.expected, quote.Node.String())
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`quote()`, "wrong number of arguments: want=1, got=0"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments: want=1, got=2"},
+		{`quote(unquote(fn() { 1 }))`, "unquote: cannot convert FUNCTION to an expression"},
+		{`quote(unquote(missing))`, "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		require.True(t, ok, "no error object returned, got %T (%+v)", evaluated, evaluated)
+		require.Equal(t, tt.expectedMessage, errObj.Message)
+	}
+}
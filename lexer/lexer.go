@@ -1,22 +1,75 @@
 package lexer
 
-import "monkey/token"
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
+// Lexer reads UTF-8 encoded source code one rune at a time
+//
+// position and readPosition are byte offsets, columns are counted in runes.
 type Lexer struct {
-	input        string
+	reader       *bufio.Reader
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+	filename string
+	line     int
+	column   int
+
+	mode   Mode
+	errors []string
+
+	// brace depth of each open ${...} interpolation, innermost last
+	interpolations []int
 }
 
+// Mode controls how the lexer treats comments and whitespace
+type Mode uint
+
+const (
+	// ScanComments records comments and blank lines as token.Trivia instead of discarding them
+	ScanComments Mode = 1 << iota
+)
+
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename(input, "")
+}
+
+// NewWithFilename returns a lexer whose token positions are reported with the given file name
+func NewWithFilename(input string, filename string) *Lexer {
+	return NewFromReader(strings.NewReader(input), filename)
+}
+
+// NewFromReader returns a lexer reading the source code from r as tokens are requested,
+// so the whole program never has to be loaded into memory
+func NewFromReader(r io.Reader, filename string) *Lexer {
+	l := &Lexer{reader: bufio.NewReader(r), filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
+// Errors returns the errors found while reading tokens so far
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+// SetMode changes the mode used for the following tokens
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	if l.mode&ScanComments != 0 {
+		return l.nextTokenWithTrivia()
+	}
 
 	for {
 		l.skipWhitespaces()
@@ -27,18 +80,73 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	start := l.currentPosition()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.currentPosition()
+
+	return tok
+}
+
+// read next token with the comments and blank lines before it, and the comment after it on the same line
+func (l *Lexer) nextTokenWithTrivia() token.Token {
+	trivia := &token.Trivia{}
+	newlines := 0
+	for {
+		if l.ch == '\n' {
+			newlines++
+		} else if l.ch == '#' {
+			comment := l.readComment()
+			comment.BlankLinesBefore = blankLines(newlines)
+			trivia.Leading = append(trivia.Leading, comment)
+			newlines = 0
+			continue
+		} else if l.ch != ' ' && l.ch != '\t' && l.ch != '\r' {
+			break
+		}
+		l.readChar()
+	}
+	trivia.BlankLinesBefore = blankLines(newlines)
+
+	start := l.currentPosition()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.currentPosition()
+
+	if tok.Type != token.EOF {
+		for l.ch == ' ' || l.ch == '\t' {
+			l.readChar()
+		}
+		if l.ch == '#' {
+			comment := l.readComment()
+			trivia.Trailing = &comment
+		}
+	}
+
+	if len(trivia.Leading) > 0 || trivia.Trailing != nil || trivia.BlankLinesBefore > 0 {
+		tok.Trivia = trivia
+	}
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: "=="}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -47,13 +155,67 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: "**"}
+		} else {
+			tok = l.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.readOperator(token.SLASH, token.SLASH_ASSIGN)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: "<="}
+		} else if l.peekChar() == '<' {
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: "<<"}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: ">="}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: ">>"}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = newToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: "|>"}
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '?':
+		if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_DOT, Literal: "?."}
+		} else if l.peekChar() == '?' {
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -63,8 +225,20 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.R_PAREN, l.ch)
 	case '{':
+		if len(l.interpolations) > 0 {
+			l.interpolations[len(l.interpolations)-1]++
+		}
 		tok = newToken(token.L_BRACE, l.ch)
 	case '}':
+		if len(l.interpolations) > 0 {
+			depth := &l.interpolations[len(l.interpolations)-1]
+			if *depth == 0 {
+				// end of the embedded expression, continue reading the string
+				l.interpolations = l.interpolations[:len(l.interpolations)-1]
+				return l.readStringSegment(token.STRING_MIDDLE, token.STRING_TAIL)
+			}
+			*depth--
+		}
 		tok = newToken(token.R_BRACE, l.ch)
 	case '[':
 		tok = newToken(token.L_BRACKET, l.ch)
@@ -72,20 +246,24 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.R_BRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = l.readDots()
 	case '"':
+		return l.readStringSegment(token.STRING_HEAD, token.STRING)
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		return tok
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -97,64 +275,316 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
 	}
+	l.column++
+
 	l.position = l.readPosition
-	l.readPosition += 1
+	ch, width, err := l.reader.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.addError(l.currentPosition(), "failed to read source: %s", err)
+		}
+		l.ch = 0
+		return
+	}
+
+	l.ch = ch
+	l.readPosition += width
+
+	if ch == utf8.RuneError && width == 1 {
+		l.addError(l.currentPosition(), "invalid UTF-8 encoding")
+	}
+}
+
+// position of the current char
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 // look ahead char
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
+//
+// only as many bytes as the next rune needs are buffered, so peeking never waits for more input than that
+func (l *Lexer) peekChar() rune {
+	for n := 1; n <= utf8.UTFMax; n++ {
+		buf, err := l.reader.Peek(n)
+		if len(buf) == 0 {
+			return 0
+		}
+		if err != nil || utf8.FullRune(buf) {
+			ch, _ := utf8.DecodeRune(buf)
+			return ch
+		}
 	}
+	return utf8.RuneError
 }
 
-// read identifiers from lexer's input string
+// read identifiers from lexer's input
 func (l *Lexer) readIdentifier() string {
-	position := l.position
-	for isLetter(l.ch) || isDigit(l.ch) {
+	var out bytes.Buffer
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
+		out.WriteRune(l.ch)
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	return out.String()
 }
 
-// read numbers from lexer's input string
-func (l *Lexer) readNumber() string {
-	position := l.position
-	for isDigit(l.ch) {
-		l.readChar()
+// read numbers from lexer's input
+//
+// a number containing a fraction or an exponent is a FLOAT, otherwise it is an INT.
+// integers may have a 0x, 0o or 0b prefix, and digits may be separated by '_'.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	var out bytes.Buffer
+
+	if l.ch == '0' {
+		if base, name := basePrefix(l.peekChar()); base != 0 {
+			l.readPrefixedInteger(&out, base, name)
+			return out.String(), token.INT
+		}
+	}
+
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits(&out, isDigit, false)
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.consumeChar(&out)
+		l.readDigits(&out, isDigit, false)
+	}
+	if (l.ch == 'e' || l.ch == 'E') && l.isExponentStart() {
+		tokenType = token.FLOAT
+		l.consumeChar(&out)
+		if l.ch == '+' || l.ch == '-' {
+			l.consumeChar(&out)
+		}
+		l.readDigits(&out, isDigit, false)
+	}
+
+	return out.String(), tokenType
+}
+
+// read integer like 0xFF, 0o755 or 0b1010 into out, the current char is the leading '0'
+func (l *Lexer) readPrefixedInteger(out *bytes.Buffer, base int, name string) {
+	start := l.currentPosition()
+	l.consumeChar(out)
+	l.consumeChar(out)
+
+	accept := isDigit
+	if base == 16 {
+		accept = isHexDigit
+	}
+
+	var digits bytes.Buffer
+	count := l.readDigits(&digits, accept, true)
+	out.Write(digits.Bytes())
+	if count == 0 {
+		l.addError(start, "%s literal has no digits", name)
+		return
+	}
+
+	for _, ch := range digits.String() {
+		if ch != '_' && hexValue(ch) >= base {
+			l.addError(start, "invalid digit %q in %s literal", ch, name)
+			return
+		}
+	}
+}
+
+// read digits and '_' separators into out, returning the number of digits
+//
+// a separator must be placed between two digits, or directly after a base prefix
+func (l *Lexer) readDigits(out *bytes.Buffer, accept func(rune) bool, afterPrefix bool) int {
+	count := 0
+	previousIsDigit := afterPrefix
+	reported := false
+
+	for accept(l.ch) || l.ch == '_' {
+		if l.ch != '_' {
+			count++
+			previousIsDigit = true
+		} else {
+			if (!previousIsDigit || !accept(l.peekChar())) && !reported {
+				l.addError(l.currentPosition(), "'_' must separate successive digits")
+				reported = true
+			}
+			previousIsDigit = false
+		}
+		l.consumeChar(out)
+	}
+
+	return count
+}
+
+// write the current char to out and advance to the next one
+func (l *Lexer) consumeChar(out *bytes.Buffer) {
+	out.WriteRune(l.ch)
+	l.readChar()
+}
+
+// return true if the 'e' at the current char starts an exponent like e10, e+10 or e-10
+func (l *Lexer) isExponentStart() bool {
+	next := l.peekChar()
+	if next == '+' || next == '-' {
+		// the sign is a single byte, so the digit is the second buffered byte
+		buf, _ := l.reader.Peek(2)
+		return len(buf) == 2 && isDigit(rune(buf[1]))
 	}
+	return isDigit(next)
+}
 
-	return l.input[position:l.position]
+// read string from the current char ('"' or the '}' closing an interpolation) up to and including
+// the closing '"' or the next "${"
+//
+// the token type is interpolatedType if the string continues after an embedded expression, otherwise endType
+func (l *Lexer) readStringSegment(interpolatedType, endType token.TokenType) token.Token {
+	var tok token.Token
+	tok.Literal, tok.Type = l.readString(interpolatedType, endType)
+	l.readChar()
+	return tok
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// read string literal from lexer's input, decoding escape sequences
+func (l *Lexer) readString(interpolatedType, endType token.TokenType) (string, token.TokenType) {
+	var out bytes.Buffer
+	start := l.currentPosition()
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '"':
+			return out.String(), endType
+		case 0:
+			l.addError(start, "unterminated string literal")
+			return out.String(), endType
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.interpolations = append(l.interpolations, 0)
+				return out.String(), interpolatedType
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
-	return l.input[position:l.position]
 }
 
-func (l *Lexer) readComment() {
+// read raw string literal between backticks, it may span lines and escapes are not processed
+func (l *Lexer) readRawString() string {
+	var out bytes.Buffer
+	start := l.currentPosition()
+
 	for {
 		l.readChar()
-		if l.ch == '\n' || l.ch == '\r' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return out.String()
+		}
+		if l.ch == 0 {
+			l.addError(start, "unterminated raw string literal")
+			return out.String()
 		}
+		out.WriteRune(l.ch)
 	}
 }
 
+// read escape sequence after '\' and write the decoded value to out
+func (l *Lexer) readEscape(out *bytes.Buffer) {
+	start := l.currentPosition()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"':
+		out.WriteByte('"')
+	case '$':
+		out.WriteByte('$')
+	case '\\':
+		out.WriteByte('\\')
+	case 'x':
+		value, ok := l.readHexDigits(2, 2)
+		if !ok {
+			l.addError(start, "invalid escape sequence: \\x must be followed by 2 hex digits")
+			return
+		}
+		if value >= utf8.RuneSelf {
+			// strings are always valid UTF-8, so \x is limited to ASCII
+			l.addError(start, "invalid escape sequence: \\x%02x is not ASCII, use \\u{%x} instead", value, value)
+			return
+		}
+		out.WriteByte(byte(value))
+	case 'u':
+		if l.peekChar() != '{' {
+			l.addError(start, "invalid escape sequence: \\u must be followed by {")
+			return
+		}
+		l.readChar()
+
+		value, ok := l.readHexDigits(1, 6)
+		if !ok || l.peekChar() != '}' {
+			l.addError(start, "invalid escape sequence: \\u{...} must contain 1 to 6 hex digits")
+			return
+		}
+		l.readChar()
+
+		if !utf8.ValidRune(rune(value)) {
+			l.addError(start, "invalid escape sequence: \\u{%x} is not a valid code point", value)
+			return
+		}
+		out.WriteRune(rune(value))
+	case 0:
+		// unterminated string, reported by readString
+	default:
+		l.addError(start, "unknown escape sequence: \\%c", l.ch)
+	}
+}
+
+// read between min and max hex digits following the current char
+func (l *Lexer) readHexDigits(min, max int) (int, bool) {
+	value := 0
+	count := 0
+	for count < max && isHexDigit(l.peekChar()) {
+		l.readChar()
+		value = value*16 + hexValue(l.ch)
+		count++
+	}
+	return value, count >= min
+}
+
+// read comment from the current '#' up to the end of the line
+func (l *Lexer) readComment() token.Comment {
+	var out bytes.Buffer
+	comment := token.Comment{Pos: l.currentPosition()}
+
+	for l.ch != '\n' && l.ch != '\r' && l.ch != 0 {
+		out.WriteRune(l.ch)
+		l.readChar()
+	}
+
+	comment.Text = out.String()
+	comment.End = l.currentPosition()
+	return comment
+}
+
+// add error prefixed with the position it occurred at
+func (l *Lexer) addError(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
 // Skip whitespaces
 func (l *Lexer) skipWhitespaces() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
@@ -162,17 +592,79 @@ func (l *Lexer) skipWhitespaces() {
 	}
 }
 
+// return the number of blank lines in a run of whitespace containing the given number of line breaks
+func blankLines(newlines int) int {
+	if newlines < 2 {
+		return 0
+	}
+	return newlines - 1
+}
+
+// return the compound assignment token if the current char is followed by '=', like "+=", otherwise the operator token
+func (l *Lexer) readOperator(operator, compound token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: compound, Literal: string(ch) + "="}
+	}
+	return newToken(operator, l.ch)
+}
+
+// return "." or "..." as a token, ".." is illegal
+func (l *Lexer) readDots() token.Token {
+	if l.peekChar() != '.' {
+		return newToken(token.DOT, l.ch)
+	}
+	l.readChar()
+	if l.peekChar() != '.' {
+		return token.Token{Type: token.ILLEGAL, Literal: ".."}
+	}
+	l.readChar()
+	return token.Token{Type: token.ELLIPSIS, Literal: "..."}
+}
+
 // Construct token.Token object with arguments
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// return true if input arg is letter
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+// return true if input arg is letter, including non-ASCII letters
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-// return true if input arg is digit
-func isDigit(ch byte) bool {
+// return true if input arg is ASCII digit
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+// return true if input arg is hex digit
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+// return the base and the name of a number prefix like 0x, or 0 if ch is not a prefix
+func basePrefix(ch rune) (int, string) {
+	switch ch {
+	case 'x', 'X':
+		return 16, "hexadecimal"
+	case 'o', 'O':
+		return 8, "octal"
+	case 'b', 'B':
+		return 2, "binary"
+	default:
+		return 0, ""
+	}
+}
+
+// return value of hex digit
+func hexValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
+}
@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
 	"monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +37,19 @@ if (5 < 10) {
 "foo bar";
 [1, 2];
 {"foo":"bar"}
+a <= b >= c && d || e;
+x += 1; x -= 2; x *= 3; x /= 4;
+a % b ** c & d | e ^ ~f << g >> h;
+[a, ...b];
+x |> f.g();
+match (x) { _ => 1 }
+a?.b ?? c;
+macro(x) {};
+try {} catch (e) {} finally {}
+throw e;
+import "m.mk" as m;
+export let
+const
 
 # this should be ignored
 `
@@ -138,6 +155,119 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.R_BRACE, "}"},
+
+		{token.IDENTIFIER, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENTIFIER, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENTIFIER, "c"},
+		{token.AND, "&&"},
+		{token.IDENTIFIER, "d"},
+		{token.OR, "||"},
+		{token.IDENTIFIER, "e"},
+		{token.SEMICOLON, ";"},
+
+		{token.IDENTIFIER, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+
+		{token.IDENTIFIER, "a"},
+		{token.PERCENT, "%"},
+		{token.IDENTIFIER, "b"},
+		{token.POWER, "**"},
+		{token.IDENTIFIER, "c"},
+		{token.BIT_AND, "&"},
+		{token.IDENTIFIER, "d"},
+		{token.BIT_OR, "|"},
+		{token.IDENTIFIER, "e"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENTIFIER, "f"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENTIFIER, "g"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENTIFIER, "h"},
+		{token.SEMICOLON, ";"},
+
+		{token.L_BRACKET, "["},
+		{token.IDENTIFIER, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "b"},
+		{token.R_BRACKET, "]"},
+		{token.SEMICOLON, ";"},
+
+		{token.IDENTIFIER, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENTIFIER, "f"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "g"},
+		{token.L_PAREN, "("},
+		{token.R_PAREN, ")"},
+		{token.SEMICOLON, ";"},
+
+		{token.MATCH, "match"},
+		{token.L_PAREN, "("},
+		{token.IDENTIFIER, "x"},
+		{token.R_PAREN, ")"},
+		{token.L_BRACE, "{"},
+		{token.IDENTIFIER, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "1"},
+		{token.R_BRACE, "}"},
+
+		{token.IDENTIFIER, "a"},
+		{token.OPTIONAL_DOT, "?."},
+		{token.IDENTIFIER, "b"},
+		{token.NULLISH, "??"},
+		{token.IDENTIFIER, "c"},
+		{token.SEMICOLON, ";"},
+
+		{token.MACRO, "macro"},
+		{token.L_PAREN, "("},
+		{token.IDENTIFIER, "x"},
+		{token.R_PAREN, ")"},
+		{token.L_BRACE, "{"},
+		{token.R_BRACE, "}"},
+		{token.SEMICOLON, ";"},
+
+		{token.TRY, "try"},
+		{token.L_BRACE, "{"},
+		{token.R_BRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.L_PAREN, "("},
+		{token.IDENTIFIER, "e"},
+		{token.R_PAREN, ")"},
+		{token.L_BRACE, "{"},
+		{token.R_BRACE, "}"},
+		{token.FINALLY, "finally"},
+		{token.L_BRACE, "{"},
+		{token.R_BRACE, "}"},
+		{token.THROW, "throw"},
+		{token.IDENTIFIER, "e"},
+		{token.SEMICOLON, ";"},
+
+		{token.IMPORT, "import"},
+		{token.STRING, "m.mk"},
+		{token.AS, "as"},
+		{token.IDENTIFIER, "m"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.CONST, "const"},
 		{token.EOF, ""},
 	}
 
@@ -148,3 +278,365 @@ if (5 < 10) {
 		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+# comment
+  add(x, "s");`
+
+	testTokens := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENTIFIER, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.IDENTIFIER, token.Position{Offset: 23, Line: 3, Column: 3}, token.Position{Offset: 26, Line: 3, Column: 6}},
+		{token.L_PAREN, token.Position{Offset: 26, Line: 3, Column: 6}, token.Position{Offset: 27, Line: 3, Column: 7}},
+		{token.IDENTIFIER, token.Position{Offset: 27, Line: 3, Column: 7}, token.Position{Offset: 28, Line: 3, Column: 8}},
+		{token.COMMA, token.Position{Offset: 28, Line: 3, Column: 8}, token.Position{Offset: 29, Line: 3, Column: 9}},
+		{token.STRING, token.Position{Offset: 30, Line: 3, Column: 10}, token.Position{Offset: 33, Line: 3, Column: 13}},
+		{token.R_PAREN, token.Position{Offset: 33, Line: 3, Column: 13}, token.Position{Offset: 34, Line: 3, Column: 14}},
+		{token.SEMICOLON, token.Position{Offset: 34, Line: 3, Column: 14}, token.Position{Offset: 35, Line: 3, Column: 15}},
+		{token.EOF, token.Position{Offset: 35, Line: 3, Column: 15}, token.Position{Offset: 35, Line: 3, Column: 15}},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedPos, token.Pos, "Wrong position at %d", index)
+		assert.Equal(t, expectedToken.expectedEnd, token.End, "Wrong end position at %d", index)
+	}
+}
+
+func TestTokenPositionsWithFilename(t *testing.T) {
+	l := NewWithFilename("\n  foo", "main.mk")
+	tok := l.NextToken()
+
+	assert.Equal(t, "main.mk:2:3", tok.Pos.String())
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"\t\r"`, "\t\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\x41\x7a"`, "Az"},
+		{`"\u{48}\u{d55c}\u{1F600}"`, "H한😀"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.EqualValues(t, token.STRING, tok.Type, "Wrong token type for %s", tt.input)
+		assert.Equal(t, tt.expected, tok.Literal, "Wrong literal for %s", tt.input)
+		assert.Empty(t, l.Errors(), "Unexpected errors for %s", tt.input)
+		assert.EqualValues(t, token.EOF, l.NextToken().Type, "Wrong token after %s", tt.input)
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"abc`, "1:1: unterminated string literal"},
+		{`"abc\`, "1:1: unterminated string literal"},
+		{`  "a\qb"`, `1:5: unknown escape sequence: \q`},
+		{`"\x4"`, `1:2: invalid escape sequence: \x must be followed by 2 hex digits`},
+		{`"\u41"`, `1:2: invalid escape sequence: \u must be followed by {`},
+		{`"\u{}"`, `1:2: invalid escape sequence: \u{...} must contain 1 to 6 hex digits`},
+		{`"\u{1234567}"`, `1:2: invalid escape sequence: \u{...} must contain 1 to 6 hex digits`},
+		{`"\u{d800}"`, `1:2: invalid escape sequence: \u{d800} is not a valid code point`},
+		{`"\xe9"`, `1:2: invalid escape sequence: \xe9 is not ASCII, use \u{e9} instead`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.EqualValues(t, token.STRING, tok.Type, "Wrong token type for %s", tt.input)
+		assert.Equal(t, []string{tt.expectedError}, l.Errors(), "Wrong errors for %s", tt.input)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"42", token.INT, "42"},
+		{"3.14", token.FLOAT, "3.14"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"2.5E+3", token.FLOAT, "2.5E+3"},
+		{"10e3", token.FLOAT, "10e3"},
+		{"0xFF", token.INT, "0xFF"},
+		{"0o755", token.INT, "0o755"},
+		{"0b1010", token.INT, "0b1010"},
+		{"0x_dead_BEEF", token.INT, "0x_dead_BEEF"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1_000.000_1e1_0", token.FLOAT, "1_000.000_1e1_0"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedType, tok.Type, "Wrong token type for %s", tt.input)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, "Wrong literal for %s", tt.input)
+		assert.EqualValues(t, token.EOF, l.NextToken().Type, "Wrong token after %s", tt.input)
+	}
+}
+
+func TestNumbersFollowedByOtherTokens(t *testing.T) {
+	input := `1.foo 2e x3 4.`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "foo"},
+		{token.INT, "2"},
+		{token.IDENTIFIER, "e"},
+		{token.IDENTIFIER, "x3"},
+		{token.INT, "4"},
+		{token.DOT, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 이름 = "값"; _변수2 + café`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENTIFIER, "이름", 5},
+		{token.ASSIGN, "=", 8},
+		{token.STRING, "값", 10},
+		{token.SEMICOLON, ";", 13},
+		{token.IDENTIFIER, "_변수2", 15},
+		{token.PLUS, "+", 20},
+		{token.IDENTIFIER, "café", 22},
+		{token.EOF, "", 26},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+		assert.Equal(t, expectedToken.expectedColumn, token.Pos.Column, "Wrong column at %d", index)
+	}
+	assert.Empty(t, l.Errors())
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("let a = \"x\xffy\";\n\xc3")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	expected := []string{
+		"1:11: invalid UTF-8 encoding",
+		"2:1: invalid UTF-8 encoding",
+	}
+	assert.Equal(t, expected, l.Errors())
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"0x", []string{"1:1: hexadecimal literal has no digits"}},
+		{"0b", []string{"1:1: binary literal has no digits"}},
+		{"0b1021", []string{"1:1: invalid digit '2' in binary literal"}},
+		{"0o789", []string{"1:1: invalid digit '8' in octal literal"}},
+		{"1__000", []string{"1:2: '_' must separate successive digits"}},
+		{"1000_", []string{"1:5: '_' must separate successive digits"}},
+		{"1.5_e3", []string{"1:4: '_' must separate successive digits"}},
+		{"0x_", []string{
+			"1:3: '_' must separate successive digits",
+			"1:1: hexadecimal literal has no digits",
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.input, tok.Literal, "Wrong literal for %s", tt.input)
+		assert.Equal(t, tt.expectedErrors, l.Errors(), "Wrong errors for %s", tt.input)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${user["name"]}, ${ {"a": 1}["a"] } and ${"nested ${x}"}!" "\${no}"`
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "Hello "},
+		{token.IDENTIFIER, "user"},
+		{token.L_BRACKET, "["},
+		{token.STRING, "name"},
+		{token.R_BRACKET, "]"},
+		{token.STRING_MIDDLE, ", "},
+		{token.L_BRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.R_BRACE, "}"},
+		{token.L_BRACKET, "["},
+		{token.STRING, "a"},
+		{token.R_BRACKET, "]"},
+		{token.STRING_MIDDLE, " and "},
+		{token.STRING_HEAD, "nested "},
+		{token.IDENTIFIER, "x"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_TAIL, "!"},
+		{token.STRING, "${no}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+	}
+	assert.Empty(t, l.Errors())
+}
+
+func TestRawStrings(t *testing.T) {
+	input := "let q = `SELECT *\n  FROM t\n WHERE a = \"\\d+\\n\"`;\nx `${not} interpolated`"
+
+	testTokens := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+		expectedEnd     string
+	}{
+		{token.LET, "let", "1:1", "1:4"},
+		{token.IDENTIFIER, "q", "1:5", "1:6"},
+		{token.ASSIGN, "=", "1:7", "1:8"},
+		{token.STRING, "SELECT *\n  FROM t\n WHERE a = \"\\d+\\n\"", "1:9", "3:20"},
+		{token.SEMICOLON, ";", "3:20", "3:21"},
+		{token.IDENTIFIER, "x", "4:1", "4:2"},
+		{token.STRING, "${not} interpolated", "4:3", "4:24"},
+		{token.EOF, "", "4:24", "4:24"},
+	}
+
+	l := New(input)
+	for index, expectedToken := range testTokens {
+		token := l.NextToken()
+		assert.Equal(t, expectedToken.expectedType, token.Type, "Wrong token type at %d", index)
+		assert.Equal(t, expectedToken.expectedLiteral, token.Literal, "Wrong literal at %d", index)
+		assert.Equal(t, expectedToken.expectedPos, token.Pos.String(), "Wrong position at %d", index)
+		assert.Equal(t, expectedToken.expectedEnd, token.End.String(), "Wrong end position at %d", index)
+	}
+	assert.Empty(t, l.Errors())
+}
+
+func TestUnterminatedRawString(t *testing.T) {
+	l := New("x\n  `abc\ndef")
+	l.NextToken()
+	tok := l.NextToken()
+
+	assert.EqualValues(t, token.STRING, tok.Type)
+	assert.Equal(t, "abc\ndef", tok.Literal)
+	assert.Equal(t, []string{"2:3: unterminated raw string literal"}, l.Errors())
+}
+
+func TestNewFromReader(t *testing.T) {
+	input := "let 이름 = \"값 ${1.5e+3 + 0x_FF}\";\n" +
+		"let raw = `a\nb`; # comment\n" +
+		"fn(x, y) { x <= y != 1_000 }; 2e 3.x"
+
+	expected := New(input)
+	l := NewFromReader(iotest.OneByteReader(strings.NewReader(input)), "")
+	for {
+		expectedToken := expected.NextToken()
+		tok := l.NextToken()
+		assert.Equal(t, expectedToken, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	assert.Equal(t, expected.Errors(), l.Errors())
+}
+
+func TestNewFromReaderReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let a\n = 1"), iotest.ErrReader(errors.New("connection reset")))
+	l := NewFromReader(r, "stdin")
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	assert.Equal(t, []token.TokenType{token.LET, token.IDENTIFIER, token.ASSIGN, token.INT}, types)
+	assert.Equal(t, []string{"stdin:2:5: failed to read source: connection reset"}, l.Errors())
+}
+
+func TestScanComments(t *testing.T) {
+	input := "# header\n\n\n# doc\nlet a = 1; # trailing\n\nreturn a;\n# footer"
+	l := New(input)
+	l.SetMode(ScanComments)
+
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	assert.Len(t, tokens, 9)
+
+	assert.Equal(t, &token.Trivia{
+		Leading: []token.Comment{
+			{Text: "# header", Pos: token.Position{Offset: 0, Line: 1, Column: 1}, End: token.Position{Offset: 8, Line: 1, Column: 9}},
+			{Text: "# doc", Pos: token.Position{Offset: 11, Line: 4, Column: 1}, End: token.Position{Offset: 16, Line: 4, Column: 6}, BlankLinesBefore: 2},
+		},
+	}, tokens[0].Trivia)
+	assert.Nil(t, tokens[1].Trivia)
+	assert.Equal(t, &token.Trivia{
+		Trailing: &token.Comment{Text: "# trailing", Pos: token.Position{Offset: 28, Line: 5, Column: 12}, End: token.Position{Offset: 38, Line: 5, Column: 22}},
+	}, tokens[4].Trivia)
+	assert.Equal(t, &token.Trivia{BlankLinesBefore: 1}, tokens[5].Trivia)
+	assert.Equal(t, []token.Comment{
+		{Text: "# footer", Pos: token.Position{Offset: 50, Line: 8, Column: 1}, End: token.Position{Offset: 58, Line: 8, Column: 9}},
+	}, tokens[8].Trivia.Leading)
+
+	// tokens themselves are unchanged
+	plain := New(input)
+	for _, tok := range tokens {
+		expected := plain.NextToken()
+		tok.Trivia = nil
+		assert.Equal(t, expected, tok)
+	}
+}
@@ -2,12 +2,24 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
+	"monkey/vm"
 	"os"
 	"os/user"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runFile(os.Args[1]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -17,3 +29,50 @@ func main() {
 	fmt.Printf("Feel free to type in commands!\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// run the script at path, or the script piped to stdin if path is "-", and return the exit code
+func runFile(path string) int {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	} else {
+		path = "stdin"
+	}
+
+	p := parser.New(lexer.NewFromReader(in, path))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		return 1
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	comp := compiler.New()
+	comp.SetImporter(module.NewLoader(module.SearchPath(os.Getenv("MONKEY_PATH"))))
+	if err := comp.Compile(expanded); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
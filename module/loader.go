@@ -0,0 +1,132 @@
+// Package module loads the modules imported by a program, each one compiled into its own bytecode
+package module
+
+import (
+	"errors"
+	"fmt"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loader finds, compiles and caches the imported modules, it implements compiler.Importer
+type Loader struct {
+	// directories searched for the modules not found next to their importer
+	searchPath []string
+
+	// compiled modules by absolute file name
+	modules map[string]*object.CompiledModule
+
+	// files being compiled, each one imported by the previous one
+	loading []string
+}
+
+func NewLoader(searchPath []string) *Loader {
+	return &Loader{searchPath: searchPath, modules: map[string]*object.CompiledModule{}}
+}
+
+// SearchPath returns the directories of a search path like the MONKEY_PATH environment variable
+func SearchPath(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return filepath.SplitList(list)
+}
+
+// Import returns the module imported as path by the file named from, compiling it the first time
+func (l *Loader) Import(path string, from string) (*object.CompiledModule, error) {
+	file, err := l.resolve(path, from)
+	if err != nil {
+		return nil, err
+	}
+	key, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if module, ok := l.modules[key]; ok {
+		return module, nil
+	}
+
+	// the program importing the first module is the start of the chain of imports
+	chain := l.loading
+	if len(chain) == 0 {
+		chain = []string{from}
+	}
+	for i, importer := range chain {
+		if abs, err := filepath.Abs(importer); err == nil && abs == key {
+			cycle := append(append([]string{}, chain[i:]...), file)
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	loading := l.loading
+	l.loading = append(chain, file)
+	defer func() { l.loading = loading }()
+
+	module, err := l.compile(file)
+	if err != nil {
+		return nil, err
+	}
+	l.modules[key] = module
+	return module, nil
+}
+
+// find the file of path, next to the importing file or else in a directory of the search path
+func (l *Loader) resolve(path string, from string) (string, error) {
+	if filepath.IsAbs(path) {
+		if isFile(path) {
+			return path, nil
+		}
+		return "", fmt.Errorf("module %s not found", path)
+	}
+
+	dirs := append([]string{filepath.Dir(from)}, l.searchPath...)
+	for _, dir := range dirs {
+		file := filepath.Join(dir, path)
+		if isFile(file) {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("module %s not found", path)
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// parse the file, expand its macros and compile it into a module
+func (l *Loader) compile(file string) (*object.CompiledModule, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	p := parser.New(lexer.NewFromReader(in, file))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	comp := compiler.New()
+	comp.SetImporter(l)
+	err = comp.Compile(expanded)
+	if err != nil {
+		return nil, err
+	}
+	return comp.Module(file), nil
+}
@@ -0,0 +1,122 @@
+package module
+
+import (
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib/strings.mk": `export let shout = fn(s) { s + "!" };`,
+		"lib/twice.mk":   `import "strings.mk" as str; export let twice = fn(s) { str.shout(str.shout(s)) };`,
+		"path/answer.mk": "export let answer = 42;",
+		"main.mk":        "",
+	})
+	main := filepath.Join(dir, "main.mk")
+	loader := NewLoader([]string{filepath.Join(dir, "path")})
+
+	strings, err := loader.Import("lib/strings.mk", main)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "lib/strings.mk"), strings.Name)
+	require.Contains(t, strings.Exports, "shout")
+
+	twice, err := loader.Import("lib/twice.mk", main)
+	require.NoError(t, err)
+	require.Same(t, strings, twice.Constants[0], "strings.mk compiled twice")
+
+	again, err := loader.Import("lib/../lib/strings.mk", main)
+	require.NoError(t, err)
+	require.Same(t, strings, again)
+
+	answer, err := loader.Import("answer.mk", main)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "path/answer.mk"), answer.Name)
+
+	result := run(t, loader, main, `import "lib/twice.mk" as t; import "answer.mk" as a; t.twice(a.answer.str())`)
+	require.Equal(t, "42!!", result.Inspect())
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.mk":      `import "b.mk" as b;`,
+		"b.mk":      `import "a.mk" as a;`,
+		"self.mk":   `import "self.mk" as self;`,
+		"syntax.mk": "let = 1;",
+		"lib":       "",
+	})
+	main := filepath.Join(dir, "main.mk")
+	a := filepath.Join(dir, "a.mk")
+	b := filepath.Join(dir, "b.mk")
+	self := filepath.Join(dir, "self.mk")
+
+	tests := []struct {
+		path          string
+		from          string
+		expectedError string
+	}{
+		{"missing.mk", main, "module missing.mk not found"},
+		{"/missing.mk", main, "module /missing.mk not found"},
+		{"lib", main, "module lib not found"},
+		{"a.mk", main, b + ":1:1: import cycle: " + a + " -> " + b + " -> " + a},
+		{"b.mk", a, b + ":1:1: import cycle: " + a + " -> " + b + " -> " + a},
+		{"self.mk", main, self + ":1:1: import cycle: " + self + " -> " + self},
+		{"syntax.mk", main, filepath.Join(dir, "syntax.mk") + ":1:5: expected next token to be IDENTIFIER, got = instead"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewLoader(nil).Import(tt.path, tt.from)
+		require.Error(t, err, tt.path)
+		require.Contains(t, err.Error(), tt.expectedError, tt.path)
+	}
+
+	// the cycle is reported once, where it closes, and not again by every import on the way
+	comp := compiler.New()
+	comp.SetImporter(NewLoader(nil))
+	err := comp.Compile(parser.New(lexer.NewWithFilename(`import "a.mk" as a;`, main)).ParseProgram())
+	require.EqualError(t, err, b+":1:1: import cycle: "+a+" -> "+b+" -> "+a)
+}
+
+func TestSearchPath(t *testing.T) {
+	require.Nil(t, SearchPath(""))
+	require.Equal(t, []string{"a", "b"}, SearchPath("a"+string(os.PathListSeparator)+"b"))
+}
+
+// write the files of a map by path in a new directory and return the directory, a file with empty content is a
+// directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			require.NoError(t, os.MkdirAll(path, 0o755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+// compile and run the input as the file named filename and return its last popped value
+func run(t *testing.T, loader *Loader, filename string, input string) object.Object {
+	t.Helper()
+
+	comp := compiler.New()
+	comp.SetImporter(loader)
+	err := comp.Compile(parser.New(lexer.NewWithFilename(input, filename)).ParseProgram())
+	require.NoError(t, err, "compiler error")
+
+	machine := vm.New(comp.Bytecode())
+	require.NoError(t, machine.Run(), "vm error")
+	return machine.LastPoppedStackElement()
+}
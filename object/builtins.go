@@ -1,11 +1,15 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 var Builtins = []struct {
 	Name    string
 	Builtin *Builtin
 }{
+	// len counts the elements of an array or the code points (not bytes) of a string
 	{
 		"len",
 		&Builtin{
@@ -17,7 +21,9 @@ var Builtins = []struct {
 				case *Array:
 					return &Integer{Value: int64(len(arg.Elements))}
 				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *Range:
+					return &Integer{Value: arg.Len()}
 				default:
 					return newError("argument to `len` not supported, got %s", args[0].Type())
 				}
@@ -130,12 +136,68 @@ var Builtins = []struct {
 			},
 		},
 	},
+	{
+		"str",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				return ToString(args[0])
+			},
+		},
+	},
+	// range(end), range(start, end) or range(start, end, step) returns the integers from start up to end
+	{
+		"range",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("wrong number of arguments. got=%d, want=1..3", len(args))
+				}
+
+				values := []int64{0, 0, 1}
+				for i, arg := range args {
+					integer, ok := arg.(*Integer)
+					if !ok {
+						return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+					}
+					values[i] = integer.Value
+				}
+				if len(args) == 1 {
+					values[0], values[1] = 0, values[0]
+				}
+				if values[2] == 0 {
+					return newError("step of `range` must not be 0")
+				}
+
+				return &Range{Start: values[0], End: values[1], Step: values[2]}
+			},
+		},
+	},
 }
 
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// ToString converts obj to a string, strings are returned as is and other objects are inspected
+func ToString(obj Object) *String {
+	if str, ok := obj.(*String); ok {
+		return str
+	}
+	return &String{Value: obj.Inspect()}
+}
+
+func GetBuiltinIndexByName(name string) int {
+	for i, def := range Builtins {
+		if def.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func GetBuiltinByName(name string) *Builtin {
 	for _, def := range Builtins {
 		if def.Name == name {
@@ -3,11 +3,14 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	// the names defined as constants
+	consts map[string]bool
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, consts: map[string]bool{}}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -26,5 +29,34 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.consts, name)
+	return val
+}
+
+// SetConst sets name as a constant of this environment
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	e.consts[name] = true
 	return val
 }
+
+// IsConst reports whether the innermost environment defining name defines it as a constant
+func (e *Environment) IsConst(name string) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env.consts[name]
+		}
+	}
+	return false
+}
+
+// Assign sets name in the innermost environment defining it, it returns false if name is not defined
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
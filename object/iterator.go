@@ -0,0 +1,118 @@
+package object
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Iterator walks over the elements of an array, a hash, a string or a range
+type Iterator struct {
+	next func() (key, value Object, ok bool)
+
+	// loops with a single variable bind the key instead of the value, used for hashes
+	keyed bool
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next advances the iterator and returns the key and the value of the element, ok is false once it is exhausted.
+//
+// keys are indices for arrays, ranges and strings (counted in code points), and keys for hashes
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// Single returns the object a loop with a single variable binds for the element: the key for hashes, the value otherwise
+func (it *Iterator) Single(key, value Object) Object {
+	if it.keyed {
+		return key
+	}
+	return value
+}
+
+// NewIterator returns an iterator over obj, or false if obj can not be iterated.
+//
+// hashes are iterated in the order of SortedPairs, strings yield one character strings
+func NewIterator(obj Object) (*Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := obj.Elements
+		index := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if index >= len(elements) {
+				return nil, nil, false
+			}
+			index++
+			return &Integer{Value: int64(index - 1)}, elements[index-1], true
+		}}, true
+	case *Hash:
+		pairs := obj.SortedPairs()
+		index := 0
+		return &Iterator{keyed: true, next: func() (Object, Object, bool) {
+			if index >= len(pairs) {
+				return nil, nil, false
+			}
+			index++
+			return pairs[index-1].Key, pairs[index-1].Value, true
+		}}, true
+	case *String:
+		str := obj.Value
+		offset, index := 0, 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if offset >= len(str) {
+				return nil, nil, false
+			}
+			_, width := utf8.DecodeRuneInString(str[offset:])
+			char := str[offset : offset+width]
+			offset += width
+			index++
+			return &Integer{Value: int64(index - 1)}, &String{Value: char}, true
+		}}, true
+	case *Range:
+		length := obj.Len()
+		start, step := obj.Start, obj.Step
+		var index int64
+		return &Iterator{next: func() (Object, Object, bool) {
+			if index >= length {
+				return nil, nil, false
+			}
+			index++
+			return &Integer{Value: index - 1}, &Integer{Value: start + (index-1)*step}, true
+		}}, true
+	default:
+		return nil, false
+	}
+}
+
+// SortedPairs returns the pairs of the hash ordered by the type of their key, then by the key itself
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+func keyLess(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return false
+	}
+}
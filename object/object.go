@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -14,10 +17,13 @@ type BuiltinFunction func(args ...Object) Object
 
 const (
 	INTEGER_OBJ           = "INTEGER"
+	FLOAT_OBJ             = "FLOAT"
 	STRING_OBJ            = "STRING"
 	BOOLEAN_OBJ           = "BOOLEAN"
 	NULL_OBJ              = "NULL"
 	RETURN_VALUE_OBJ      = "RETURN_VALUE"
+	BREAK_OBJ             = "BREAK"
+	CONTINUE_OBJ          = "CONTINUE"
 	ERROR_OBJ             = "ERROR"
 	FUNCTION_OBJ          = "FUNCTION"
 	BUILTIN_OBJ           = "BUILTIN"
@@ -26,6 +32,13 @@ const (
 	HASH_OBJ              = "HASH_OBJ"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	CLOSURE_OBJ           = "CLOSURE"
+	RANGE_OBJ             = "RANGE"
+	ITERATOR_OBJ          = "ITERATOR"
+	CELL_OBJ              = "CELL"
+	QUOTE_OBJ             = "QUOTE"
+	MACRO_OBJ             = "MACRO"
+	MODULE_OBJ            = "MODULE"
+	COMPILED_MODULE_OBJ   = "COMPILED_MODULE"
 )
 
 type Object interface {
@@ -53,6 +66,24 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// Float object
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		// keep integral floats distinguishable from integers
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // String object
 type String struct {
 	Value string
@@ -99,17 +130,38 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break object, signals a break statement to the enclosing loop
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue object, signals a continue statement to the enclosing loop
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 // ERROR object
 type Error struct {
 	Message string
+	Pos     token.Position // position of the expression which raised the error, if known
+	Value   Object         // the value of a throw statement, nil for a runtime error
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
 
 // Function object
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default value of each parameter, nil for a required one
+	Rest       *ast.Identifier  // nil if the function has no rest parameter
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -118,8 +170,15 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
@@ -129,6 +188,32 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Quote object, the unevaluated node passed to quote
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Macro object, expanded before the program is evaluated or compiled
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	return "macro(" + strings.Join(params, ", ") + ") {\n" + m.Body.String() + "\n}"
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -149,6 +234,32 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// Range is the lazy sequence of integers from Start up to (not including) End
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len returns the number of integers in the range
+func (r *Range) Len() int64 {
+	if r.Step > 0 && r.Start < r.End {
+		return (r.End - r.Start + r.Step - 1) / r.Step
+	}
+	if r.Step < 0 && r.Start > r.End {
+		return (r.Start - r.End - r.Step - 1) / -r.Step
+	}
+	return 0
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -175,8 +286,12 @@ func (h *Hash) Inspect() string {
 
 type CompiledFunction struct {
 	Instructions  code.Instructions
+	SourceMap     code.SourceMap
 	NumLocals     int
-	NumParameters int
+	NumParameters int // not counting the rest parameter, which is the local after the parameters
+
+	MinArity int // number of parameters without a default
+	MaxArity int // -1 if the function has a rest parameter
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -184,9 +299,23 @@ func (cf *CompiledFunction) Inspect() string {
 	return fmt.Sprintf("CompiledFunction[%p]", cf)
 }
 
+// WrongArity returns the message for a call with got arguments to a function taking min to max arguments,
+// max is -1 for a function with a rest parameter
+func WrongArity(min, max, got int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("wrong number of arguments: want at least %d, got=%d", min, got)
+	case min != max:
+		return fmt.Sprintf("wrong number of arguments: want=%d to %d, got=%d", min, max, got)
+	default:
+		return fmt.Sprintf("wrong number of arguments: want=%d, got=%d", min, got)
+	}
+}
+
 type Closure struct {
-	Fn   *CompiledFunction
-	Free []Object
+	Fn     *CompiledFunction
+	Free   []Object
+	Module *Module // the module the function was compiled in, whose constants and globals it uses
 }
 
 func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
@@ -194,6 +323,56 @@ func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
 
+// CompiledModule is a module compiled into its own bytecode, with its own constants and globals
+type CompiledModule struct {
+	Name         string
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []Object
+	NumGlobals   int
+	Exports      map[string]int // index of the global bound to each exported name
+}
+
+func (cm *CompiledModule) Type() ObjectType { return COMPILED_MODULE_OBJ }
+func (cm *CompiledModule) Inspect() string {
+	return fmt.Sprintf("CompiledModule[%s]", cm.Name)
+}
+
+// Module is the namespace of a loaded module, holding the values of its globals
+type Module struct {
+	Name      string
+	Constants []Object
+	Globals   []Object
+	Exports   map[string]int // index of the global bound to each exported name
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	return "MODULE(" + m.Name + ")"
+}
+
+// Export returns the value of the exported name, false if the module does not export it
+func (m *Module) Export(name string) (Object, bool) {
+	index, ok := m.Exports[name]
+	if !ok {
+		return nil, false
+	}
+	return m.Globals[index], true
+}
+
+// Cell holds a local variable captured by a closure, so the function and its closures share the variable
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell()"
+	}
+	return fmt.Sprintf("cell(%s)", c.Value.Inspect())
+}
+
 // Built-in
 type Builtin struct {
 	Fn BuiltinFunction
@@ -17,3 +17,101 @@ func TestStringHashKey(t *testing.T) {
 	require.Equal(t, diff1.HashKey(), diff2.HashKey())
 	require.NotEqual(t, hello1.HashKey(), diff1.HashKey())
 }
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, (&Float{Value: tt.value}).Inspect())
+	}
+}
+
+func TestHashSortedPairs(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{&String{Value: "b"}, &Integer{Value: 10}, &String{Value: "a"}, &Integer{Value: -1}, &Boolean{Value: true}, &Boolean{Value: false}} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: &Null{}}
+	}
+
+	keys := []string{}
+	for _, pair := range hash.SortedPairs() {
+		keys = append(keys, pair.Key.Inspect())
+	}
+	require.Equal(t, []string{"false", "true", "-1", "10", "a", "b"}, keys)
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        *Range
+		expected int64
+	}{
+		{&Range{Start: 0, End: 10, Step: 1}, 10},
+		{&Range{Start: 0, End: 10, Step: 3}, 4},
+		{&Range{Start: 10, End: 0, Step: -3}, 4},
+		{&Range{Start: 10, End: 0, Step: 1}, 0},
+		{&Range{Start: 0, End: 10, Step: -1}, 0},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, tt.r.Len(), tt.r.Inspect())
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		index    int64
+		length   int
+		expected int
+		ok       bool
+	}{
+		{0, 3, 0, true},
+		{2, 3, 2, true},
+		{3, 3, 0, false},
+		{-1, 3, 2, true},
+		{-3, 3, 0, true},
+		{-4, 3, 0, false},
+		{0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		position, ok := Position(tt.index, tt.length)
+		require.Equal(t, tt.ok, ok, "index %d of %d", tt.index, tt.length)
+		require.Equal(t, tt.expected, position, "index %d of %d", tt.index, tt.length)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	null := &Null{}
+	integer := func(i int64) Object { return &Integer{Value: i} }
+
+	tests := []struct {
+		obj       Object
+		low, high Object
+		expected  string
+	}{
+		{&String{Value: "monkey"}, integer(1), integer(3), "on"},
+		{&String{Value: "monkey"}, null, integer(-3), "mon"},
+		{&String{Value: "monkey"}, integer(-100), integer(100), "monkey"},
+		{&String{Value: "monkey"}, integer(4), integer(2), ""},
+		{&Array{Elements: []Object{integer(1), integer(2), integer(3)}}, integer(1), null, "[2, 3]"},
+		{&Array{Elements: []Object{integer(1), integer(2), integer(3)}}, integer(3), null, "[]"},
+	}
+
+	for _, tt := range tests {
+		slice, err := Slice(tt.obj, tt.low, tt.high)
+		require.NoError(t, err)
+		require.Equal(t, tt.expected, slice.Inspect())
+	}
+
+	_, err := Slice(&Boolean{Value: true}, null, null)
+	require.EqualError(t, err, "slice operator not supported: BOOLEAN")
+	_, err = Slice(&String{Value: "a"}, &String{Value: "b"}, null)
+	require.EqualError(t, err, "slice bounds must be integers, got STRING")
+}
@@ -0,0 +1,74 @@
+package object
+
+import "fmt"
+
+// Position returns the position of index in a sequence of length elements, a negative index counts from the
+// end, false if the index is out of range
+func Position(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// Slice returns the elements of an array, or the characters of a string, from start up to but not including end.
+//
+// a null bound stands for the start or the end of the sequence, a negative bound counts from the end and a bound
+// out of range is clamped to the sequence, so the slice is empty instead of failing when start is not before end
+func Slice(obj, start, end Object) (Object, error) {
+	var length int
+	var runes []rune
+	switch obj := obj.(type) {
+	case *Array:
+		length = len(obj.Elements)
+	case *String:
+		runes = []rune(obj.Value)
+		length = len(runes)
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", obj.Type())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return nil, err
+	}
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return nil, err
+	}
+	if to < from {
+		to = from
+	}
+
+	if array, ok := obj.(*Array); ok {
+		elements := make([]Object, to-from)
+		copy(elements, array.Elements[from:to])
+		return &Array{Elements: elements}, nil
+	}
+	return &String{Value: string(runes[from:to])}, nil
+}
+
+// return the position of a bound of a slice, omitted if the bound is null
+func sliceBound(bound Object, omitted int, length int) (int, error) {
+	switch bound := bound.(type) {
+	case *Null:
+		return omitted, nil
+	case *Integer:
+		i := bound.Value
+		if i < 0 {
+			i += int64(length)
+		}
+		if i < 0 {
+			return 0, nil
+		}
+		if i > int64(length) {
+			return length, nil
+		}
+		return int(i), nil
+	default:
+		return 0, fmt.Errorf("slice bounds must be integers, got %s", bound.Type())
+	}
+}
@@ -1,36 +1,69 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	PIPE
+	NULLISH
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
+	BIT_OR
+	BIT_XOR
+	BIT_AND
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
 	INDEX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:        EQUALS,
-	token.NOT_EQ:    EQUALS,
-	token.LT:        LESSGREATER,
-	token.GT:        LESSGREATER,
-	token.PLUS:      SUM,
-	token.MINUS:     SUM,
-	token.SLASH:     PRODUCT,
-	token.ASTERISK:  PRODUCT,
-	token.L_PAREN:   CALL,
-	token.L_BRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.AND:             LOGICAL_AND,
+	token.OR:              LOGICAL_OR,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.BIT_AND:         BIT_AND,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.L_PAREN:         CALL,
+	token.L_BRACKET:       INDEX,
+	token.DOT:             INDEX,
+	token.OPTIONAL_DOT:    INDEX,
+	token.PIPE:            PIPE,
+	token.NULLISH:         NULLISH,
 }
 
 type (
@@ -42,9 +75,28 @@ type Parser struct {
 	l      *lexer.Lexer
 	errors []string
 
+	// number of lexer errors already copied to errors
+	numLexerErrors int
+
 	currentToken token.Token
 	peekToken    token.Token
 
+	// token to return as the peek token again, see backUp
+	backedUpToken *token.Token
+
+	// number of braces open around the current token and number of block statements being parsed
+	braceDepth   int
+	blockNesting int
+
+	// number of loops around the current token within the current function
+	loopDepth int
+
+	// set after a syntax error until the parser resynchronizes at the end of the statement
+	panicking bool
+
+	// comments read so far, when the lexer preserves comments
+	comments []token.Comment
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -62,14 +114,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefixParseFn(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefixParseFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixParseFn(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefixParseFn(token.STRING, p.parseStringLiteral)
+	p.registerPrefixParseFn(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefixParseFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixParseFn(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefixParseFn(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefixParseFn(token.TRUE, p.parseBoolean)
 	p.registerPrefixParseFn(token.FALSE, p.parseBoolean)
 	p.registerPrefixParseFn(token.L_PAREN, p.parseGroupedExpression)
 	p.registerPrefixParseFn(token.IF, p.parseIfExpression)
+	p.registerPrefixParseFn(token.MATCH, p.parseMatchExpression)
+	p.registerPrefixParseFn(token.TRY, p.parseTryExpression)
 	p.registerPrefixParseFn(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefixParseFn(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefixParseFn(token.L_BRACKET, p.parseArrayLiteral)
 	p.registerPrefixParseFn(token.L_BRACE, p.parseHashLiteral)
 
@@ -78,12 +136,32 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixParseFn(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfixParseFn(token.LT, p.parseInfixExpression)
 	p.registerInfixParseFn(token.GT, p.parseInfixExpression)
+	p.registerInfixParseFn(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfixParseFn(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfixParseFn(token.AND, p.parseInfixExpression)
+	p.registerInfixParseFn(token.OR, p.parseInfixExpression)
+	p.registerInfixParseFn(token.NULLISH, p.parseInfixExpression)
 	p.registerInfixParseFn(token.PLUS, p.parseInfixExpression)
 	p.registerInfixParseFn(token.MINUS, p.parseInfixExpression)
 	p.registerInfixParseFn(token.SLASH, p.parseInfixExpression)
 	p.registerInfixParseFn(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfixParseFn(token.PERCENT, p.parseInfixExpression)
+	p.registerInfixParseFn(token.POWER, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfixParseFn(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfixParseFn(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfixParseFn(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfixParseFn(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfixParseFn(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixParseFn(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixParseFn(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfixParseFn(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfixParseFn(token.L_PAREN, p.parseCallExpression)
 	p.registerInfixParseFn(token.L_BRACKET, p.parseIndexExpression)
+	p.registerInfixParseFn(token.DOT, p.parseDotExpression)
+	p.registerInfixParseFn(token.OPTIONAL_DOT, p.parseOptionalIndexExpression)
+	p.registerInfixParseFn(token.PIPE, p.parsePipeExpression)
 
 	return p
 }
@@ -102,6 +180,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
@@ -115,38 +194,185 @@ func (p *Parser) registerInfixParseFn(tokenType token.TokenType, fn infixParseFn
 }
 
 func (p *Parser) nextToken() {
+	switch p.currentToken.Type {
+	case token.L_BRACE:
+		p.braceDepth++
+	case token.R_BRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
+
 	p.currentToken = p.peekToken
+	if p.backedUpToken != nil {
+		p.peekToken = *p.backedUpToken
+		p.backedUpToken = nil
+		return
+	}
 	p.peekToken = p.l.NextToken()
+	p.comments = append(p.comments, p.peekToken.Trivia.Comments()...)
+
+	if lexerErrors := p.l.Errors(); len(lexerErrors) > p.numLexerErrors {
+		p.errors = append(p.errors, lexerErrors[p.numLexerErrors:]...)
+		p.numLexerErrors = len(lexerErrors)
+	}
+}
+
+// backUp makes the current token the peek token again, so the next call to nextToken returns it
+func (p *Parser) backUp() {
+	peek := p.peekToken
+	p.backedUpToken = &peek
+	p.peekToken = p.currentToken
+	p.currentToken = token.Token{}
 }
 
 // Parse function entrypoint
+//
+// after a syntax error the rest of the statement is skipped, so every error is reported once
 func (p *Parser) parseStatement() ast.Statement {
+	if p.panicking {
+		// an enclosing statement failed already, it resynchronizes once this one returns
+		return p.parseStatementByType()
+	}
+
+	depth := p.braceDepth
+	statement := p.parseStatementByType()
+	if p.panicking {
+		p.synchronize(depth)
+		p.panicking = false
+		return nil
+	}
+	return statement
+}
+
+// skip tokens up to the end of the statement whose first token was at the given brace depth
+//
+// the current token is left on the ';' ending the statement, or before the token starting the next one
+func (p *Parser) synchronize(depth int) {
+	for !p.currentTokenIs(token.EOF) {
+		if p.braceDepth <= depth {
+			if p.currentTokenIs(token.R_BRACE) && p.blockNesting > 0 {
+				// the brace closes the enclosing block, leave it to parseBlockStatement
+				p.backUp()
+				return
+			}
+			if p.currentTokenIs(token.SEMICOLON) || p.peekTokenIs(token.LET) || p.peekTokenIs(token.CONST) ||
+				p.peekTokenIs(token.RETURN) || p.peekTokenIs(token.WHILE) || p.peekTokenIs(token.FOR) {
+				return
+			}
+			if p.peekTokenIs(token.R_BRACE) && p.blockNesting > 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseStatementByType() ast.Statement {
+	first := p.currentToken
+
 	switch p.currentToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		if statement := p.parseLetStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if statement := p.parseReturnStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.THROW:
+		if statement := p.parseThrowStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.IMPORT:
+		if statement := p.parseImportStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.EXPORT:
+		if statement := p.parseExportStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.WHILE:
+		if statement := p.parseWhileStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.FOR:
+		if statement := p.parseForStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.BREAK:
+		if statement := p.parseBreakStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
+	case token.CONTINUE:
+		if statement := p.parseContinueStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
 	default:
-		return p.parseExpressionStatement()
+		if statement := p.parseExpressionStatement(); statement != nil {
+			statement.Trivia = p.statementTrivia(first)
+			return statement
+		}
 	}
+	return nil
+}
+
+// build trivia of the statement starting at first and ending at the current token
+//
+// leading comments and blank lines come from the first token, the trailing comment from the last one
+func (p *Parser) statementTrivia(first token.Token) *token.Trivia {
+	last := p.currentToken
+	if first.Trivia == nil && last.Trivia == nil {
+		return nil
+	}
+
+	trivia := &token.Trivia{}
+	if first.Trivia != nil {
+		trivia.Leading = first.Trivia.Leading
+		trivia.BlankLinesBefore = first.Trivia.BlankLinesBefore
+	}
+	if last.Trivia != nil {
+		trivia.Trailing = last.Trivia.Trailing
+	}
+	if len(trivia.Leading) == 0 && trivia.Trailing == nil && trivia.BlankLinesBefore == 0 {
+		return nil
+	}
+	return trivia
 }
 
 // Parse function for LET token
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	statement := &ast.LetStatement{Token: p.currentToken}
 
-	if !p.expectPeek(token.IDENTIFIER) {
-		return nil
+	if p.peekTokenIs(token.L_BRACKET) || p.peekTokenIs(token.L_BRACE) {
+		p.nextToken()
+		statement.Pattern = p.parsePattern(false)
+		if statement.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 	}
 
-	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 
 	statement.Value = p.parseExpression(LOWEST)
-	if fl, ok := statement.Value.(*ast.FunctionLiteral); ok {
+	if fl, ok := statement.Value.(*ast.FunctionLiteral); ok && statement.Name != nil {
 		fl.Name = statement.Name.Value
 	}
 
@@ -157,6 +383,143 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return statement
 }
 
+// parse the pattern starting at the current token, an identifier or a nested array or hash pattern,
+// refutable patterns of match arms may also be literals
+func (p *Parser) parsePattern(refutable bool) ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENTIFIER:
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.L_BRACKET:
+		return p.parseArrayPattern(refutable)
+	case token.L_BRACE:
+		return p.parseHashPattern(refutable)
+	}
+
+	if refutable {
+		switch p.currentToken.Type {
+		case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE:
+			value := p.prefixParseFns[p.currentToken.Type]()
+			if value == nil {
+				return nil
+			}
+			return &ast.LiteralPattern{Value: value}
+		case token.MINUS:
+			if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+				value := p.parsePrefixExpression()
+				if value == nil {
+					return nil
+				}
+				return &ast.LiteralPattern{Value: value}
+			}
+		}
+	}
+
+	p.addError(p.currentToken.Pos, "expected a pattern, got %s instead", p.currentToken.Type)
+	return nil
+}
+
+// parse a pattern followed by an optional default value, refutable patterns have no defaults
+func (p *Parser) parseBindingElement(refutable bool) *ast.BindingElement {
+	target := p.parsePattern(refutable)
+	if target == nil {
+		return nil
+	}
+	element := &ast.BindingElement{Target: target}
+
+	if !refutable && p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		p.nextToken()
+		element.Default = p.parseExpression(ASSIGN)
+		if element.Default == nil {
+			return nil
+		}
+	}
+	return element
+}
+
+func (p *Parser) parseArrayPattern(refutable bool) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.R_BRACKET) {
+		p.nextToken()
+
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			// the rest element must be the last one
+			break
+		}
+
+		element := p.parseBindingElement(refutable)
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.R_BRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.R_BRACKET) {
+		return nil
+	}
+	pattern.EndToken = p.currentToken
+
+	// an empty pattern binds nothing, but [] still matches an empty array in a match arm
+	if len(pattern.Elements) == 0 && pattern.Rest == nil && !refutable {
+		p.addError(pattern.Pos(), "empty pattern")
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(refutable bool) ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.currentToken}
+
+	for !p.peekTokenIs(token.R_BRACE) {
+		p.nextToken()
+
+		key := &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+		var value *ast.BindingElement
+		switch {
+		case p.currentTokenIs(token.IDENTIFIER) && !p.peekTokenIs(token.COLON):
+			// shorthand binding the key to a variable of the same name
+			value = p.parseBindingElement(refutable)
+		case p.currentTokenIs(token.IDENTIFIER) || p.currentTokenIs(token.STRING):
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+			value = p.parseBindingElement(refutable)
+		default:
+			p.addError(p.currentToken.Pos, "expected a hash pattern key, got %s instead", p.currentToken.Type)
+			return nil
+		}
+		if value == nil {
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, &ast.HashPatternPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.R_BRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.R_BRACE) {
+		return nil
+	}
+	pattern.EndToken = p.currentToken
+
+	if len(pattern.Pairs) == 0 && !refutable {
+		p.addError(pattern.Pos(), "empty pattern")
+		return nil
+	}
+	return pattern
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{Token: p.currentToken}
 
@@ -170,6 +533,22 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	statement := &ast.ThrowStatement{Token: p.currentToken}
+
+	p.nextToken()
+
+	statement.Value = p.parseExpression(LOWEST)
+	if statement.Value == nil {
+		return nil
+	}
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{Token: p.currentToken}
 	statement.Expression = p.parseExpression(LOWEST)
@@ -188,9 +567,25 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: p.currentToken}
 
-	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
+	value, err := parseInteger(p.currentToken.Literal)
+	if errors.Is(err, strconv.ErrRange) {
+		p.addError(p.currentToken.Pos, "integer literal %s overflows int64 (max %d)", p.currentToken.Literal, int64(math.MaxInt64))
+		return nil
+	} else if err != nil {
+		// malformed literals are already reported by the lexer
+		return nil
+	}
+
+	literal.Value = value
+	return literal
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: p.currentToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.currentToken.Literal, "_", ""), 64)
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal))
+		p.addError(p.currentToken.Pos, "could not parse %q as float", p.currentToken.Literal)
 		return nil
 	}
 
@@ -202,10 +597,170 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.currentToken}
+	str.Segments = []string{p.currentToken.Literal}
+
+	for {
+		p.nextToken()
+		if p.currentTokenIs(token.STRING_MIDDLE) || p.currentTokenIs(token.STRING_TAIL) {
+			p.addError(p.currentToken.Pos, "empty expression in string interpolation")
+			return nil
+		}
+		str.Expressions = append(str.Expressions, p.parseExpression(LOWEST))
+
+		p.nextToken()
+		switch p.currentToken.Type {
+		case token.STRING_MIDDLE:
+			str.Segments = append(str.Segments, p.currentToken.Literal)
+		case token.STRING_TAIL:
+			str.Segments = append(str.Segments, p.currentToken.Literal)
+			str.EndToken = p.currentToken
+			return str
+		default:
+			p.addError(p.currentToken.Pos, "expected } to close string interpolation, got %s instead", p.currentToken.Type)
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currentToken, Value: p.currentTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	statement := &ast.WhileStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_PAREN) {
+		return nil
+	}
+	p.nextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.R_PAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+	p.loopDepth++
+	statement.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	statement := &ast.ForStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_PAREN) {
+		return nil
+	}
+	for {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Variables = append(statement.Variables, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+		if len(statement.Variables) == 2 || !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.R_PAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+	p.loopDepth++
+	statement.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{Token: p.currentToken}
+	if p.blockNesting > 0 {
+		p.addError(p.currentToken.Pos, "import is not at the top level")
+		return nil
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	statement.Path = &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	statement := &ast.ExportStatement{Token: p.currentToken}
+	if p.blockNesting > 0 {
+		p.addError(p.currentToken.Pos, "export is not at the top level")
+		return nil
+	}
+
+	if p.peekTokenIs(token.CONST) {
+		p.nextToken()
+	} else if !p.expectPeek(token.LET) {
+		return nil
+	}
+	statement.Statement = p.parseLetStatement()
+	if statement.Statement == nil {
+		return nil
+	}
+	return statement
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	statement := &ast.BreakStatement{Token: p.currentToken}
+	if p.loopDepth == 0 {
+		p.addError(p.currentToken.Pos, "break is not in a loop")
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	statement := &ast.ContinueStatement{Token: p.currentToken}
+	if p.loopDepth == 0 {
+		p.addError(p.currentToken.Pos, "continue is not in a loop")
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.currentToken}
 
@@ -236,10 +791,134 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.L_PAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENTIFIER) {
+				return nil
+			}
+			expression.Param = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			if !p.expectPeek(token.R_PAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.L_BRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.L_BRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.addError(p.peekToken.Pos, "expected catch or finally after try block, got %s instead", p.peekToken.Type)
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_PAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.R_PAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.R_BRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		// arms are separated by optional commas
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.R_BRACE) {
+		return nil
+	}
+	expression.EndToken = p.currentToken
+
+	return expression
+}
+
+// parse pattern [if guard] => body, the body is a block or a single expression
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern(true)}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+		if arm.Guard == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.L_BRACE) {
+		p.nextToken()
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	p.nextToken()
+	statement := &ast.ExpressionStatement{Token: p.currentToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	if statement.Expression == nil {
+		return nil
+	}
+	arm.Body = &ast.BlockStatement{
+		Token:      statement.Token,
+		Statements: []ast.Statement{statement},
+		EndToken:   token.Token{End: statement.Expression.End()},
+	}
+	return arm
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
 	block.Statements = []ast.Statement{}
 
+	p.blockNesting++
+	defer func() { p.blockNesting-- }()
+
 	p.nextToken()
 	for !p.currentTokenIs(token.R_BRACE) && !p.currentTokenIs(token.EOF) {
 		if statement := p.parseStatement(); statement != nil {
@@ -247,10 +926,41 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.EndToken = p.currentToken
 
 	return block
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.currentToken}
+
+	if !p.expectPeek(token.L_PAREN) {
+		return nil
+	}
+	for !p.peekTokenIs(token.R_PAREN) {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		macro.Parameters = append(macro.Parameters, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+
+		if !p.peekTokenIs(token.R_PAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	if !p.expectPeek(token.L_BRACE) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	macro.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return macro
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fl := &ast.FunctionLiteral{
 		Token: p.currentToken,
@@ -259,12 +969,19 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.L_PAREN) {
 		return nil
 	}
-	fl.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fl) {
+		return nil
+	}
 
 	if !p.expectPeek(token.L_BRACE) {
 		return nil
 	}
+
+	// loops outside of the function can not be left from its body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	fl.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return fl
 }
@@ -272,6 +989,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
 	array.Elements = p.parseExpressionList(token.R_BRACKET)
+	array.EndToken = p.currentToken
 	return array
 }
 
@@ -300,32 +1018,60 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.R_BRACE) {
 		return nil
 	}
+	hash.EndToken = p.currentToken
 
 	return hash
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parse the parameters, their defaults and the rest parameter of fl, it returns false on a syntax error
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	fl.Parameters = []*ast.Identifier{}
+	fl.Defaults = []ast.Expression{}
 	if p.peekTokenIs(token.R_PAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
 	for {
 		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+			fl.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			// the rest parameter must be the last one
+			break
+		}
+
+		if !p.currentTokenIs(token.IDENTIFIER) {
+			p.addError(p.currentToken.Pos, "expected a parameter, got %s instead", p.currentToken.Type)
+			return false
+		}
 		ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
+
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parseExpression(ASSIGN)
+			if value == nil {
+				return false
+			}
+		} else if len(fl.Defaults) > 0 && fl.Defaults[len(fl.Defaults)-1] != nil {
+			// every argument up to the last given one is bound in order, so a required parameter can not follow an optional one
+			p.addError(ident.Pos(), "parameter %s without a default follows a parameter with a default", ident.Value)
+			return false
+		}
+		fl.Parameters = append(fl.Parameters, ident)
+		fl.Defaults = append(fl.Defaults, value)
+
 		if !p.peekTokenIs(token.COMMA) {
 			break
 		}
 		p.nextToken()
 	}
 
-	if !p.expectPeek(token.R_PAREN) {
-		return nil
-	}
-
-	return identifiers
+	return p.expectPeek(token.R_PAREN)
 }
 
 // Pratt parser
@@ -336,6 +1082,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	leftExpression := prefix()
+	if leftExpression == nil {
+		return nil
+	}
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedences() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -376,27 +1125,169 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedences := p.currentPrecedences()
+	if expression.Token.Type == token.POWER {
+		// ** is right associative
+		precedences--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedences)
 
 	return expression
 }
 
+// parse assignment to the identifier or index expression target, it is right associative
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Target:   target,
+	}
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		// a?.b is not an assignment target as there is nothing to assign to when a is null
+		if target.Optional {
+			p.addError(target.Pos(), "cannot assign to %s", target.String())
+			return nil
+		}
+	default:
+		p.addError(target.Pos(), "cannot assign to %s", target.String())
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	if expression.Value == nil {
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: p.currentToken, Function: function}
 	expression.Arguments = p.parseExpressionList(token.R_PAREN)
+	expression.EndToken = p.currentToken
 	return expression
 }
 
+// parse value |> f(a) as f(value, a), and value |> f as f(value)
+func (p *Parser) parsePipeExpression(value ast.Expression) ast.Expression {
+	pipe := p.currentToken
+	precedences := p.currentPrecedences()
+	p.nextToken()
+	function := p.parseExpression(precedences)
+	if function == nil {
+		return nil
+	}
+
+	if call, ok := function.(*ast.CallExpression); ok {
+		call.Arguments = append([]ast.Expression{value}, call.Arguments...)
+		return call
+	}
+	return &ast.CallExpression{
+		Token:     pipe,
+		Function:  function,
+		Arguments: []ast.Expression{value},
+		EndToken:  token.Token{End: function.End()},
+	}
+}
+
+// parse receiver.f(a) as f(receiver, a) and receiver.key as receiver["key"], the compiler calls the function f of
+// the module instead when receiver is bound by an import statement
+func (p *Parser) parseDotExpression(receiver ast.Expression) ast.Expression {
+	dot := p.currentToken
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	function := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if !p.peekTokenIs(token.L_PAREN) {
+		return &ast.IndexExpression{
+			Token:    dot,
+			Left:     receiver,
+			Index:    &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal},
+			EndToken: p.currentToken,
+		}
+	}
+	p.nextToken()
+	call := p.parseCallExpression(function).(*ast.CallExpression)
+	call.Arguments = append([]ast.Expression{receiver}, call.Arguments...)
+	call.Receiver = true
+	return call
+}
+
+// parse left?.key as left?.["key"], left?.[index] and left?.[low:high], all are null when left is null
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	optionalDot := p.currentToken
+
+	if p.peekTokenIs(token.L_BRACKET) {
+		p.nextToken()
+		switch expression := p.parseIndexExpression(left).(type) {
+		case *ast.IndexExpression:
+			expression.Token = optionalDot
+			expression.Optional = true
+			return expression
+		case *ast.SliceExpression:
+			expression.Token = optionalDot
+			expression.Optional = true
+			return expression
+		}
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	return &ast.IndexExpression{
+		Token:    optionalDot,
+		Left:     left,
+		Index:    &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal},
+		Optional: true,
+		EndToken: p.currentToken,
+	}
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	expression := &ast.IndexExpression{Token: p.currentToken, Left: left}
 
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		expression.Index = p.parseExpression(LOWEST)
+		if expression.Index == nil {
+			return nil
+		}
+	}
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(expression.Token, left, expression.Index)
+	}
+
+	if !p.expectPeek(token.R_BRACKET) {
+		return nil
+	}
+	expression.EndToken = p.currentToken
+
+	return expression
+}
+
+// parse the rest of left[low:high] from the colon, low is nil when omitted
+func (p *Parser) parseSliceExpression(bracket token.Token, left ast.Expression, low ast.Expression) ast.Expression {
+	expression := &ast.SliceExpression{Token: bracket, Left: left, Low: low}
 	p.nextToken()
-	expression.Index = p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.R_BRACKET) {
+		p.nextToken()
+		expression.High = p.parseExpression(LOWEST)
+		if expression.High == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.R_BRACKET) {
 		return nil
 	}
+	expression.EndToken = p.currentToken
 
 	return expression
 }
@@ -425,6 +1316,28 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return args
 }
 
+// parse integer literal with an optional 0x, 0o or 0b prefix and '_' digit separators
+func parseInteger(literal string) (int64, error) {
+	digits := strings.ReplaceAll(literal, "_", "")
+
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = digits[2:]
+		}
+	}
+
+	return strconv.ParseInt(digits, base, 64)
+}
+
 // return true if type of current token is t
 func (p *Parser) currentTokenIs(t token.TokenType) bool {
 	return p.currentToken.Type == t
@@ -462,11 +1375,21 @@ func (p *Parser) currentPrecedences() int {
 	return LOWEST
 }
 
+// add error prefixed with the position it occurred at
+func (p *Parser) addError(pos token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		// most likely caused by the error already reported for this statement
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
 // add error for token
 func (p *Parser) peekError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type))
+	p.addError(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t))
+	p.addError(p.currentToken.Pos, "no prefix parse function for %s found", t)
 }
@@ -35,6 +35,82 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+		expectedConst  bool
+	}{
+		{"const LIMIT = 100;", "const LIMIT = 100;", true},
+		{"const [a, ...b] = c;", "const [a, ...b] = c;", true},
+		{"let x = 1;", "let x = 1;", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		require.True(t, ok, "statement is not *ast.LetStatement. got=%T", program.Statements[0])
+		require.Equal(t, tt.expectedConst, statement.Const())
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [head, second, ...tail] = arr;", "let [head, second, ...tail] = arr;"},
+		{"let [...all] = arr;", "let [...all] = arr;"},
+		{"let [a, b = 1 + 2] = arr;", "let [a, b = (1 + 2)] = arr;"},
+		{"let {name, age} = user;", "let {name, age} = user;"},
+		{`let {name: n, "home town": town = "seoul"} = user;`, `let {"name": n, "home town": town = "seoul"} = user;`},
+		{"let {address: {city}, tags: [first]} = user;", `let {"address": {city}, "tags": [first]} = user;`},
+		{"let [[a, b], {c}] = pairs;", "let [[a, b], {c}] = pairs;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		require.True(t, ok, "statement is not ast.LetStatement, got %T", program.Statements[0])
+		require.Nil(t, statement.Name)
+		require.NotNil(t, statement.Pattern)
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let [a, 1] = arr;", "1:9: expected a pattern, got INT instead"},
+		{"let [...rest, a] = arr;", "1:13: expected next token to be ], got , instead"},
+		{"let [...[a]] = arr;", "1:9: expected next token to be IDENTIFIER, got [ instead"},
+		{"let {1: a} = h;", "1:6: expected a hash pattern key, got INT instead"},
+		{"let [a b] = arr;", "1:8: expected next token to be ,, got IDENTIFIER instead"},
+		{"let [] = arr;", "1:5: empty pattern"},
+		{"let [a, {}] = arr;", "1:9: empty pattern"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	tests := []struct {
 		input               string
@@ -87,6 +163,61 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, 5, statement.Expression)
 }
 
+func TestPrefixedIntegerLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF;", 255},
+		{"0Xff;", 255},
+		{"0o755;", 493},
+		{"0b1010;", 10},
+		{"1_000_000;", 1000000},
+		{"0x7fff_ffff_ffff_ffff;", 9223372036854775807},
+		{"0755;", 755},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+		require.Equal(t, 1, len(program.Statements), "statement does not contain 1 statements, %s", program.Statements)
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+		integer, ok := statement.Expression.(*ast.IntegerLiteral)
+		require.True(t, ok, "Expression is not integer literal, %s", statement.Expression)
+		require.Equal(t, tt.expected, integer.Value, "Wrong value")
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5e3;", 2500},
+		{"1_000.5;", 1000.5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+		require.Equal(t, 1, len(program.Statements), "statement does not contain 1 statements, %s", program.Statements)
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+		float, ok := statement.Expression.(*ast.FloatLiteral)
+		require.True(t, ok, "Expression is not float literal, %s", statement.Expression)
+		require.Equal(t, tt.expected, float.Value, "Wrong value")
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world!";`
 
@@ -101,6 +232,48 @@ func TestStringLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, "hello world!", statement.Expression)
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"Hello ${user["name"]}, you have ${len(items) + 1} items"`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+	require.Equal(t, 1, len(program.Statements), "statement does not contain 1 statements, %s", program.Statements)
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "statements[0] is not ExpressionStatement, %s", program.Statements[0])
+	str, ok := statement.Expression.(*ast.InterpolatedString)
+	require.True(t, ok, "Expression is not InterpolatedString, %s", statement.Expression)
+
+	require.Equal(t, []string{"Hello ", ", you have ", " items"}, str.Segments)
+	require.Equal(t, 2, len(str.Expressions), "len(Expressions) != 2, %s", str.Expressions)
+	require.Equal(t, `(user["name"])`, str.Expressions[0].String())
+	require.Equal(t, `(len(items) + 1)`, str.Expressions[1].String())
+	require.Equal(t, `"Hello ${(user["name"])}, you have ${(len(items) + 1)} items"`, str.String())
+	require.Equal(t, "1:1", str.Pos().String())
+	require.Equal(t, "1:58", str.End().String())
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "1:6: empty expression in string interpolation"},
+		{`"a ${x y} b"`, "1:8: expected } to close string interpolation, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
 func TestBooleanLiteralExpression(t *testing.T) {
 	input := "true;"
 
@@ -159,6 +332,10 @@ func TestParsingInfixExpression(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
 		{"true != false;", true, "!=", false},
 		{"false != false;", false, "!=", false},
 		{"false != true;", false, "!=", true},
@@ -207,6 +384,54 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b*c) +d", "((a + add((b * c))) + d)"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1,2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:b + 1]", "(a[:(b + 1)])"},
+		{"a[-2:]", "(a[(-2):])"},
+		{"a[:]", "(a[:])"},
+		{"a[1:][0] * 2", "(((a[1:])[0]) * 2)"},
+		{"a <= b == b >= a", "((a <= b) == (b >= a))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"!a && b", "((!a) && b)"},
+		{"a = b = c", "(a = (b = c))"},
+		{"a += b || c", "(a += (b || c))"},
+		{"a[i + 1] *= 2 + 3", "((a[(i + 1)]) *= (2 + 3))"},
+		{"f(x = 1)", "f((x = 1))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a << b + c", "(a << (b + c))"},
+		{"a & b << c", "(a & (b << c))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a | b == c", "((a | b) == c)"},
+		{"a < b & c", "(a < (b & c))"},
+		{"~a & b", "((~a) & b)"},
+		{"a |> f", "f(a)"},
+		{"a |> f(b)", "f(a, b)"},
+		{"a |> f(b) |> g", "g(f(a, b))"},
+		{"a + b |> f(c * d)", "f((a + b), (c * d))"},
+		{"a || b |> f", "f((a || b))"},
+		{"x = a |> f", "(x = f(a))"},
+		{"a.f()", "f(a)"},
+		{"a.f(b).g(c, d)", "g(f(a, b), c, d)"},
+		{"-a.f()", "(-f(a))"},
+		{"a[0].f(1)", "f((a[0]), 1)"},
+		{"a + b.f()", "(a + f(b))"},
+		{"a.f() |> g(b.h())", "g(f(a), h(b))"},
+		{"a.b", `(a["b"])`},
+		{"a.b.c(d).e", `(c((a["b"]), d)["e"])`},
+		{"a.b = 1", `((a["b"]) = 1)`},
+		{"a?.b?.c", `((a?.["b"])?.["c"])`},
+		{"a?.[i + 1]", "(a?.[(i + 1)])"},
+		{"a?.[1:]", "(a?.[1:])"},
+		{"a?.[:b]?.c", `((a?.[:b])?.["c"])`},
+		{"a?.b.c", `((a?.["b"])["c"])`},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a || b ?? c && d", "((a || b) ?? (c && d))"},
+		{"a.b ?? -1", `((a["b"]) ?? (-1))`},
+		{"x = a ?? b |> f", "(x = f((a ?? b)))"},
 	}
 	for _, precedenceTest := range precedenceTests {
 		l := lexer.New(precedenceTest.input)
@@ -317,6 +542,233 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"fn(a, b = 10) { a + b }", "fn(a, b = 10)(a + b)"},
+		{"fn(a = 1, b = a * 2) { b }", "fn(a = 1, b = (a * 2))b"},
+		{"fn(a, ...rest) { rest }", "fn(a, ...rest)rest"},
+		{"fn(...args) { args }", "fn(...args)args"},
+		{"fn(a, b = [1, 2], ...rest) { a }", "fn(a, b = [1, 2], ...rest)a"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	require.Len(t, program.Statements, 1)
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "statement is not ast.ExpressionStatement, got %T", program.Statements[0])
+	macro, ok := statement.Expression.(*ast.MacroLiteral)
+	require.True(t, ok, "expression is not ast.MacroLiteral, got %T", statement.Expression)
+
+	require.Len(t, macro.Parameters, 2)
+	testLiteralExpression(t, "x", macro.Parameters[0])
+	testLiteralExpression(t, "y", macro.Parameters[1])
+	require.Len(t, macro.Body.Statements, 1)
+	require.Equal(t, "macro(x, y)(x + y)", program.String())
+
+	p = New(lexer.New("macro(x, 1) { x }"))
+	p.ParseProgram()
+	require.NotEmpty(t, p.Errors(), "expected parser errors")
+	require.Equal(t, "1:10: expected next token to be IDENTIFIER, got INT instead", p.Errors()[0])
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(a = 1, b) { }", "1:11: parameter b without a default follows a parameter with a default"},
+		{"fn(...rest, a) { }", "1:11: expected next token to be ), got , instead"},
+		{"fn(1) { }", "1:4: expected a parameter, got INT instead"},
+		{"fn(...) { }", "1:7: expected next token to be IDENTIFIER, got ) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{`match (x) { 1 => "one", 2 => "two" }`, `match (x) { 1 => "one", 2 => "two" }`},
+		{"match (x) { -1 => a, 2.5 => b, true => c, _ => d }", "match (x) { (-1) => a, 2.5 => b, true => c, _ => d }"},
+		{"match (x) { [] => 0, [a, b] => a + b, [head, ...tail] => head }", "match (x) { [] => 0, [a, b] => (a + b), [head, ...tail] => head }"},
+		{`match (x) { {"type": "user", "name": n} => n, {} => null }`, `match (x) { {"type": "user", "name": n} => n, {} => null }`},
+		{"match (x) { n if n > 0 => n, _ => { let y = 1; y } }", "match (x) { n if (n > 0) => n, _ => let y = 1;y }"},
+		{"match (x) {\n  [1, [a]] => a\n  {name} => name\n}", `match (x) { [1, [a]] => a, {name} => name }`},
+		{"match (x) { }", "match (x) {  }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "statement is not ast.ExpressionStatement, got %T", program.Statements[0])
+		_, ok = statement.Expression.(*ast.MatchExpression)
+		require.True(t, ok, "expression is not ast.MatchExpression, got %T", statement.Expression)
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"match x { _ => 1 }", "1:7: expected next token to be (, got IDENTIFIER instead"},
+		{"match (x) { a + 1 => 1 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { -a => 1 }", "1:13: expected a pattern, got - instead"},
+		{"match (x) { [a = 1] => a }", "1:16: expected next token to be ,, got = instead"},
+		{"match (x) { _ if => 1 }", "1:18: no prefix parse function for => found"},
+		{"let [1] = x;", "1:6: expected a pattern, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"try { f() } catch (e) { e }", "try f() catch(e) e"},
+		{"try { f() } catch { 0 }", "try f() catch 0"},
+		{"try { f() } finally { g() }", "try f() finally g()"},
+		{"let x = try { f() } catch (e) { 0 } finally { g() };", "let x = try f() catch(e) 0 finally g();"},
+		{"throw x + 1;", "throw (x + 1);"},
+		{`if (x) { throw "bad" }`, `ifx throw "bad";`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"try { f() }", "1:12: expected catch or finally after try block, got EOF instead"},
+		{"try f() catch { 0 }", "1:5: expected next token to be {, got IDENTIFIER instead"},
+		{"try { f() } catch (1) { 0 }", "1:20: expected next token to be IDENTIFIER, got INT instead"},
+		{"try { f() } finally 1", "1:21: expected next token to be {, got INT instead"},
+		{"throw;", "1:6: no prefix parse function for ; found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
+func TestImportAndExportStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{`import "lib/strings.mk" as str;`, `import "lib/strings.mk" as str;`},
+		{`import "lib/strings.mk" as str; str.upper("a")`, `import "lib/strings.mk" as str;upper(str, "a")`},
+		{`import "lib/strings.mk" as str; str.name; name.upper()`, `import "lib/strings.mk" as str;(str["name"])upper(name)`},
+		{"export let x = 1;", "export let x = 1;"},
+		{"export let [a, {b}] = f();", "export let [a, {b}] = f();"},
+		{"export const x = 1;", "export const x = 1;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestReceiverCalls(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedReceiver bool
+	}{
+		{"a.f(b)", true},
+		{"f(a, b)", false},
+		{"a |> f(b)", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		call, ok := statement.Expression.(*ast.CallExpression)
+		require.True(t, ok, "expression is not *ast.CallExpression. got=%T", statement.Expression)
+		require.Equal(t, "f(a, b)", call.String())
+		require.Equal(t, tt.expectedReceiver, call.Receiver, tt.input)
+	}
+}
+
+func TestImportAndExportErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"import lib as str;", "1:8: expected next token to be STRING, got IDENTIFIER instead"},
+		{`import "lib.mk";`, "1:16: expected next token to be AS, got ; instead"},
+		{`import "lib.mk" as 1;`, "1:20: expected next token to be IDENTIFIER, got INT instead"},
+		{`fn() { import "lib.mk" as l; }`, "1:8: import is not at the top level"},
+		{"export fn() { 1 };", "1:8: expected next token to be LET, got FUNCTION instead"},
+		{"if (x) { export let y = 1; }", "1:10: export is not at the top level"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2*3, 4+5);"
 
@@ -428,7 +880,7 @@ func TestParsingHashLiterals(t *testing.T) {
 			literal, ok := key.(*ast.StringLiteral)
 			require.True(t, ok)
 
-			tt.expected[literal.String()](value)
+			tt.expected[literal.Value](value)
 		}
 	}
 }
@@ -451,6 +903,357 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	require.Equal(t, "myFunction", function.Name)
 }
 
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nadd(x;", "2:6: expected next token to be ), got ; instead"},
+		{"\n\n   ]", "3:4: no prefix parse function for ] found"},
+		{"\n  99999999999999999999", "2:3: integer literal 99999999999999999999 overflows int64 (max 9223372036854775807)"},
+		{"0x8000_0000_0000_0000", "1:1: integer literal 0x8000_0000_0000_0000 overflows int64 (max 9223372036854775807)"},
+		{"0b102", "1:1: invalid digit '2' in binary literal"},
+		{"a.1", "1:3: expected next token to be IDENTIFIER, got INT instead"},
+		{"a?.1", "1:4: expected next token to be IDENTIFIER, got INT instead"},
+		{"a?.b = 1", `1:1: cannot assign to (a?.["b"])`},
+		{"a?.[0] += 1", "1:1: cannot assign to (a?.[0])"},
+		{"a |>", "1:5: no prefix parse function for EOF found"},
+		{"let a = 1;\n1 + a = 2", "2:1: cannot assign to (1 + a)"},
+		{"f() -= 1", "1:1: cannot assign to f()"},
+		{"a[1:2] = [3]", "1:1: cannot assign to (a[1:2])"},
+		{"a[1:2:3]", "1:6: expected next token to be ], got : instead"},
+		{"a[1 2]", "1:5: expected next token to be ], got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "expected parser errors")
+		require.Equal(t, tt.expectedError, p.Errors()[0], "wrong error")
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	input := `let a = "x\qy";
+let b = "unterminated`
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []string{
+		`1:11: unknown escape sequence: \q`,
+		`2:9: unterminated string literal`,
+	}
+	require.Equal(t, expected, p.Errors(), "wrong errors")
+}
+
+func TestStringLiteralRoundTrip(t *testing.T) {
+	input := `let s = "line\n\t\"quoted\" \\ \u{d55c}\x01";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	printed := program.String()
+	require.Equal(t, `let s = "line\n\t\"quoted\" \\ 한\x01";`, printed)
+
+	l = lexer.New(printed)
+	p = New(l)
+	reparsed := p.ParseProgram()
+	testParserErrors(t, p)
+	require.Equal(t, printed, reparsed.String(), "printed program does not round-trip")
+}
+
+func TestInterpolatedStringRoundTrip(t *testing.T) {
+	input := `"cost: \${x} is ${"a\n" + str(1)}$"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	printed := program.String()
+	require.Equal(t, `"cost: \${x} is ${("a\n" + str(1))}$"`, printed)
+
+	l = lexer.New(printed)
+	p = New(l)
+	reparsed := p.ParseProgram()
+	testParserErrors(t, p)
+	require.Equal(t, printed, reparsed.String(), "printed program does not round-trip")
+}
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart string
+		expectedEnd   string
+	}{
+		{"foobar", "1:1", "1:7"},
+		{"  1 + 2 * 3", "1:3", "1:12"},
+		{"add(1,\n 2)", "1:1", "2:4"},
+		{"arr[0]", "1:1", "1:7"},
+		{"if (x) { 1 } else {\n 2\n}", "1:1", "3:2"},
+		{"let f = fn(x) { x };", "1:1", "1:20"},
+		{"return [1, 2];", "1:1", "1:14"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"let [a, {b}] = x", "1:1", "1:17"},
+		{"a |> f", "1:1", "1:7"},
+		{"a |> f(b)", "1:1", "1:10"},
+		{"a.f(b)", "1:1", "1:7"},
+		{"a.b", "1:1", "1:4"},
+		{"a?.[b]", "1:1", "1:7"},
+		{"a[1:]", "1:1", "1:6"},
+		{"match (x) {\n _ => 1\n}", "1:1", "3:2"},
+		{"match (x) { _ => 1 }", "1:1", "1:21"},
+		{"try { a } catch (e) { b }", "1:1", "1:26"},
+		{"try { a } finally {\n b\n}", "1:1", "3:2"},
+		{"throw x + 1;", "1:1", "1:12"},
+		{`import "a.mk" as a;`, "1:1", "1:19"},
+		{"export let a = 1;", "1:1", "1:17"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Equal(t, 1, len(program.Statements), "statement does not contain 1 statements, %s", program.Statements)
+		statement := program.Statements[0]
+		require.Equal(t, tt.expectedStart, statement.Pos().String(), "wrong start of %s", tt.input)
+		require.Equal(t, tt.expectedEnd, statement.End().String(), "wrong end of %s", tt.input)
+	}
+}
+
+func TestStatementComments(t *testing.T) {
+	input := `# add two numbers
+let add = fn(x, y) {
+	# the sum
+	return x + y; # done
+};
+
+add(1, 2) # call
+# end`
+	l := lexer.New(input)
+	l.SetMode(lexer.ScanComments)
+	p := New(l)
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	require.Len(t, program.Statements, 2)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	require.NotNil(t, let.Trivia)
+	require.Len(t, let.Trivia.Leading, 1)
+	require.Equal(t, "# add two numbers", let.Trivia.Leading[0].Text)
+	require.Nil(t, let.Trivia.Trailing)
+
+	body := let.Value.(*ast.FunctionLiteral).Body
+	ret := body.Statements[0].(*ast.ReturnStatement)
+	require.Equal(t, "# the sum", ret.Trivia.Leading[0].Text)
+	require.Equal(t, "# done", ret.Trivia.Trailing.Text)
+
+	call := program.Statements[1].(*ast.ExpressionStatement)
+	require.Equal(t, 1, call.Trivia.BlankLinesBefore)
+	require.Empty(t, call.Trivia.Leading)
+	require.Equal(t, "# call", call.Trivia.Trailing.Text)
+
+	texts := []string{}
+	for _, comment := range program.Comments {
+		texts = append(texts, comment.Text)
+	}
+	require.Equal(t, []string{"# add two numbers", "# the sum", "# done", "# call", "# end"}, texts)
+}
+
+func TestStatementCommentsDisabled(t *testing.T) {
+	p := New(lexer.New("# comment\nlet a = 1; # trailing"))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	require.Nil(t, program.Statements[0].(*ast.LetStatement).Trivia)
+	require.Empty(t, program.Comments)
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { if (x == 1) { continue; } break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testParserErrors(t, p)
+
+	require.Len(t, program.Statements, 1)
+	statement, ok := program.Statements[0].(*ast.WhileStatement)
+	require.True(t, ok, "statement is not ast.WhileStatement, got %T", program.Statements[0])
+	testInfixExpression(t, "x", "<", "y", statement.Condition)
+
+	require.Len(t, statement.Body.Statements, 2)
+	_, ok = statement.Body.Statements[1].(*ast.BreakStatement)
+	require.True(t, ok, "statement is not ast.BreakStatement, got %T", statement.Body.Statements[1])
+	require.Equal(t, "while(x < y) if(x == 1) continue;break;", program.String())
+}
+
+func TestLoopsWithTrailingSemicolons(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"while (x) { x = 1 }; y", "whilex (x = 1)y"},
+		{"while (x) { x = 1 };\ny", "whilex (x = 1)y"},
+		{"for (x in xs) { x }; y", "for (x in xs) xy"},
+		{"for (k, v in h) { break; };\ny", "for (k, v in h) break;y"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 2, tt.input)
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedVariables []string
+		expectedString    string
+	}{
+		{"for (x in xs) { x }", []string{"x"}, "for (x in xs) x"},
+		{"for (k, v in h) { break; }", []string{"k", "v"}, "for (k, v in h) break;"},
+		{"for (i in range(1, 10)) { continue; }", []string{"i"}, "for (i in range(1, 10)) continue;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		statement, ok := program.Statements[0].(*ast.ForStatement)
+		require.True(t, ok, "statement is not ast.ForStatement, got %T", program.Statements[0])
+
+		variables := []string{}
+		for _, v := range statement.Variables {
+			variables = append(variables, v.Value)
+		}
+		require.Equal(t, tt.expectedVariables, variables)
+		require.Equal(t, tt.expectedString, program.String())
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expectedTarget   string
+	}{
+		{"x = 5;", "=", "x"},
+		{"x += 5;", "+=", "x"},
+		{"arr[0] -= 5;", "-=", "(arr[0])"},
+		{`h["a"] *= 5;`, "*=", `(h["a"])`},
+		{"x /= 5;", "/=", "x"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		testParserErrors(t, p)
+
+		require.Len(t, program.Statements, 1)
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "statement is not ast.ExpressionStatement, got %T", program.Statements[0])
+		exp, ok := statement.Expression.(*ast.AssignExpression)
+		require.True(t, ok, "expression is not ast.AssignExpression, got %T", statement.Expression)
+
+		require.Equal(t, tt.expectedOperator, exp.Operator)
+		require.Equal(t, tt.expectedTarget, exp.Target.String())
+		testIntegerLiteral(t, 5, exp.Value)
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"if (true) { continue; }", "1:13: continue is not in a loop"},
+		{"while (true) { let f = fn() { break; }; }", "1:31: break is not in a loop"},
+		{"for (a, b, c in x) { }", "1:10: expected next token to be IN, got , instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		require.Equal(t, []string{tt.expectedError}, p.Errors(), tt.input)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements string
+	}{
+		{
+			"let x = add(1, 2;\nlet y = 3;\nlet = 5;\nreturn y;",
+			[]string{
+				"1:17: expected next token to be ), got ; instead",
+				"3:5: expected next token to be IDENTIFIER, got = instead",
+			},
+			"let y = 3;return y;",
+		},
+		{
+			"let f = fn(x {\n  x + 1;\n};\nlet g = 1;",
+			[]string{"1:14: expected next token to be ), got { instead"},
+			"let g = 1;",
+		},
+		{
+			"let f = fn() {\n  let a = ;\n  let b = 2 +;\n  a\n};\nlet c = );",
+			[]string{
+				"2:11: no prefix parse function for ; found",
+				"3:14: no prefix parse function for ; found",
+				"6:9: no prefix parse function for ) found",
+			},
+			"let f = fn<f>()a;",
+		},
+		{
+			"if (x) { let y = } else { y }; let z = 1;",
+			[]string{"1:18: no prefix parse function for } found"},
+			"ifx else ylet z = 1;",
+		},
+		{
+			"let h = fn() { let m = {\"a\": }; m };\nh(",
+			[]string{
+				"1:30: no prefix parse function for } found",
+				"2:3: no prefix parse function for EOF found",
+			},
+			"let h = fn<h>()m;",
+		},
+		{
+			"}; let a = 1; let = 2",
+			[]string{
+				"1:1: no prefix parse function for } found",
+				"1:19: expected next token to be IDENTIFIER, got = instead",
+			},
+			"let a = 1;",
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		require.Equal(t, tt.expectedErrors, p.Errors(), tt.input)
+		require.Equal(t, tt.expectedStatements, program.String(), tt.input)
+	}
+}
+
 // Helper functionss
 //
 func testParserErrors(t *testing.T, p *Parser) {
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"os"
 	"strings"
 )
 
@@ -56,6 +59,8 @@ func Start(in io.Reader, out io.Writer) {
 func StartChannel(in chan string, out chan string) {
 	constants := []object.Object{}
 	globals := make([]object.Object, 10)
+	macroEnv := object.NewEnvironment()
+	loader := module.NewLoader(module.SearchPath(os.Getenv("MONKEY_PATH")))
 	symbolTable := compiler.NewSymbolTable()
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
@@ -72,8 +77,16 @@ func StartChannel(in chan string, out chan string) {
 			continue
 		}
 
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			out <- fmt.Sprintf("Woops! Macro expansion failed:\n%s\n", err)
+			continue
+		}
+
 		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
+		comp.SetImporter(loader)
+		err = comp.Compile(expanded)
 		if err != nil {
 			out <- fmt.Sprintf("Woops! Compilation failed:\n%s\n", err)
 			continue
@@ -0,0 +1,27 @@
+package token
+
+import "fmt"
+
+// Position describes a location in the source code.
+//
+// Line and Column are 1-based, Offset is a 0-based byte offset.
+// A Position whose Line is 0 is invalid (unknown).
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns "file:line:column", "line:column" if there is no file name or "-" if the position is invalid
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
@@ -5,6 +5,9 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the token
+	Trivia  *Trivia  // comments and blank lines around the token, nil unless the lexer preserves comments
 }
 
 const (
@@ -14,8 +17,14 @@ const (
 	// identifier
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	FLOAT      = "FLOAT"
 	STRING     = "STRING"
 
+	// segments of a string with embedded expressions like "head ${a} middle ${b} tail"
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
+
 	// Operator
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -23,9 +32,28 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	AND = "&&"
+	OR  = "||"
 
 	EQ     = "=="
 	NOT_EQ = "!="
@@ -41,26 +69,63 @@ const (
 	L_BRACKET = "["
 	R_BRACKET = "]"
 
-	COLON = ":"
+	COLON    = ":"
+	DOT      = "."
+	ELLIPSIS = "..."
+	PIPE     = "|>"
+	ARROW    = "=>"
+
+	OPTIONAL_DOT = "?."
+	NULLISH      = "??"
 
 	// Reserved
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	MATCH    = "MATCH"
+	MACRO    = "MACRO"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
 )
 
 var reservedKeywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
+	"match":    MATCH,
+	"macro":    MACRO,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
 }
 
 func LookupIdentifier(ident string) TokenType {
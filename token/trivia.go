@@ -0,0 +1,34 @@
+package token
+
+// Comment is a '#' comment, Text includes the '#' but not the line break
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
+
+	// number of blank lines between the previous token or comment and this comment
+	BlankLinesBefore int
+}
+
+// Trivia holds the comments and blank lines around a token.
+//
+// It is only recorded when the lexer preserves comments.
+type Trivia struct {
+	Leading  []Comment // comments before the token, in source order
+	Trailing *Comment  // comment following the token on the same line
+
+	// number of blank lines between the last leading comment (or the previous token) and the token
+	BlankLinesBefore int
+}
+
+// Comments returns the leading and trailing comments in source order
+func (t *Trivia) Comments() []Comment {
+	if t == nil {
+		return nil
+	}
+	comments := t.Leading
+	if t.Trailing != nil {
+		comments = append(comments[:len(comments):len(comments)], *t.Trailing)
+	}
+	return comments
+}
@@ -3,6 +3,7 @@ package vm
 import (
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 )
 
 type Frame struct {
@@ -22,3 +23,11 @@ func NewFrame(cl *object.Closure, basePointer int) *Frame {
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+// Position returns the source position of the instruction being executed
+func (f *Frame) Position() (token.Position, bool) {
+	if f.cl.Fn.SourceMap == nil || f.ip < 0 {
+		return token.Position{}, false
+	}
+	return f.cl.Fn.SourceMap.Lookup(f.ip)
+}
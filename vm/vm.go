@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
@@ -16,6 +17,7 @@ var False = &object.Boolean{Value: false}
 var Null = &object.Null{}
 
 type VM struct {
+	// constants and globals of the module of the current frame
 	constants []object.Object
 
 	stack []object.Object
@@ -25,31 +27,68 @@ type VM struct {
 
 	frames      []*Frame
 	framesIndex int
+
+	// handlers installed by OpTry, innermost last
+	handlers []handler
+
+	// modules loaded by OpImport
+	modules map[*object.CompiledModule]*object.Module
+}
+
+// where to continue when an error is raised in a try block
+type handler struct {
+	catchPos    int  // position of the catch code in the instructions of the frame
+	framesIndex int  // number of frames when the handler was installed
+	sp          int  // stack pointer when the handler was installed
+	finally     bool // installed by OpTryFinally, the catch code gets the error to raise it again
+}
+
+// thrownError is raised by a throw statement
+type thrownError struct {
+	value object.Object
+}
+
+func (e *thrownError) Error() string {
+	return "uncaught exception: " + e.value.Inspect()
+}
+
+// raisedError is an error raised again after the finally block of a try expression, with the message and the
+// position of the original error
+type raisedError struct {
+	err *object.Error
+}
+
+func (e *raisedError) Error() string {
+	return e.err.Message
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
-	mainClosure := &object.Closure{Fn: mainFn}
+	mainModule := &object.Module{Constants: bytecode.Constants, Globals: make([]object.Object, GlobalsSize)}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	mainClosure := &object.Closure{Fn: mainFn, Module: mainModule}
 	mainFrame := NewFrame(mainClosure, 0)
 
 	frames := make([]*Frame, MaxFrame)
 	frames[0] = mainFrame
 
 	return &VM{
-		constants: bytecode.Constants,
+		constants: mainModule.Constants,
 
 		stack: make([]object.Object, StackSize),
 		sp:    0,
 
-		globals: make([]object.Object, GlobalsSize),
+		globals: mainModule.Globals,
 
 		frames:      frames,
 		framesIndex: 1,
+
+		modules: map[*object.CompiledModule]*object.Module{},
 	}
 }
 
 func NewWithGlobalState(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	vm := New(bytecode)
+	vm.frames[0].cl.Module.Globals = s
 	vm.globals = s
 	return vm
 }
@@ -61,7 +100,72 @@ func (vm *VM) StackTop() object.Object {
 	return vm.stack[vm.sp-1]
 }
 
+// Run executes the bytecode. Runtime errors are passed to the innermost handler, the ones which are not
+// caught are prefixed with the source position of the failing instruction
 func (vm *VM) Run() error {
+	for {
+		err := vm.run()
+		if err == nil {
+			return nil
+		}
+		if len(vm.handlers) == 0 {
+			var raised *raisedError
+			if errors.As(err, &raised) && raised.err.Pos.IsValid() {
+				return fmt.Errorf("%s: %w", raised.err.Pos, err)
+			}
+			if pos, ok := vm.currentFrame().Position(); ok {
+				return fmt.Errorf("%s: %w", pos, err)
+			}
+			return err
+		}
+
+		err = vm.catch(err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// unwind the frames and the stack to the innermost handler and continue at its catch code with the thrown
+// value, or the message of a runtime error, on the stack. the catch code of an OpTryFinally handler gets the
+// error itself
+func (vm *VM) catch(err error) error {
+	raised := vm.raisedError(err)
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.framesIndex = h.framesIndex
+	vm.useModule(vm.currentFrame().cl.Module)
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.catchPos - 1
+
+	if h.finally {
+		return vm.push(raised)
+	}
+	if raised.Value != nil {
+		return vm.push(raised.Value)
+	}
+	return vm.push(&object.String{Value: raised.Message})
+}
+
+// return the error object of err, positioned at the instruction being executed unless it is raised again
+func (vm *VM) raisedError(err error) *object.Error {
+	var raised *raisedError
+	if errors.As(err, &raised) {
+		return raised.err
+	}
+
+	obj := &object.Error{Message: err.Error()}
+	obj.Pos, _ = vm.currentFrame().Position()
+	var thrown *thrownError
+	if errors.As(err, &thrown) {
+		obj.Value = thrown.value
+	}
+	return obj
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -98,12 +202,14 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual,
+			code.OpLessThan, code.OpLessThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -118,6 +224,11 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpBitNot:
+			err := vm.executeBitNotOperator()
+			if err != nil {
+				return err
+			}
 
 		case code.OpJump:
 			pos := int(code.ReadUint16(ins[ip+1:]))
@@ -130,6 +241,15 @@ func (vm *VM) Run() error {
 			if !isTruthy(condition) {
 				vm.currentFrame().ip = pos - 1
 			}
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] != Null {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
 
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
@@ -149,14 +269,35 @@ func (vm *VM) Run() error {
 
 			frame := vm.currentFrame()
 
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
 		case code.OpGetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
 			frame := vm.currentFrame()
 
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			err := vm.push(deref(vm.stack[frame.basePointer+int(localIndex)]))
+			if err != nil {
+				return err
+			}
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+			err := vm.push(cell)
 			if err != nil {
 				return err
 			}
@@ -194,6 +335,57 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			slice, err := object.Slice(left, low, high)
+			if err != nil {
+				return err
+			}
+			err = vm.push(slice)
+			if err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(left, index, value)
+			if err != nil {
+				return err
+			}
+		case code.OpRest:
+			start := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.executeRest(vm.pop(), start)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchArray:
+			length := int(code.ReadUint16(ins[ip+1:]))
+			hasRest := code.ReadUint8(ins[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			array, ok := vm.pop().(*object.Array)
+			matched := ok && (len(array.Elements) == length || hasRest && len(array.Elements) >= length)
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchHash:
+			numKeys := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.executeMatchHash(numKeys)
+			if err != nil {
+				return err
+			}
 
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
@@ -221,6 +413,37 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpTry, code.OpTryFinally:
+			catchPos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{
+				catchPos:    catchPos,
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+				finally:     op == code.OpTryFinally,
+			})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			value := vm.pop()
+			if err, ok := value.(*object.Error); ok {
+				return &raisedError{err: err}
+			}
+			return &thrownError{value: value}
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.importModule(vm.constants[constIndex].(*object.CompiledModule))
+			if err != nil {
+				return err
+			}
+		case code.OpEndModule:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
@@ -243,6 +466,25 @@ func (vm *VM) Run() error {
 			freeIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(deref(currentClosure.Free[freeIndex]))
+			if err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			free := vm.currentFrame().cl.Free
+			if cell, ok := free[freeIndex].(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				free[freeIndex] = vm.pop()
+			}
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
@@ -254,6 +496,49 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+
+		case code.OpIter:
+			iterable := vm.pop()
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("%s is not iterable", iterable.Type())
+			}
+			err := vm.push(iterator)
+			if err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			numValues := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.executeIterNext(pos, int(numValues))
+			if err != nil {
+				return err
+			}
+		case code.OpStackDepth:
+			err := vm.push(&object.Integer{Value: int64(vm.sp - vm.currentFrame().basePointer)})
+			if err != nil {
+				return err
+			}
+		case code.OpUnwind:
+			depth := vm.pop().(*object.Integer)
+			vm.sp = vm.currentFrame().basePointer + int(depth.Value)
+
+		case code.OpDup:
+			err := vm.push(vm.stack[vm.sp-1])
+			if err != nil {
+				return err
+			}
+		case code.OpDup2:
+			err := vm.push(vm.stack[vm.sp-2])
+			if err != nil {
+				return err
+			}
+			err = vm.push(vm.stack[vm.sp-2])
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -288,13 +573,45 @@ func (vm *VM) currentFrame() *Frame {
 func (vm *VM) pushFrame(f *Frame) {
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
+	vm.useModule(f.cl.Module)
 }
 
 func (vm *VM) popFrame() *Frame {
 	vm.framesIndex--
+	vm.useModule(vm.currentFrame().cl.Module)
 	return vm.frames[vm.framesIndex]
 }
 
+// switch to the constants and globals of module, the module of the current frame
+func (vm *VM) useModule(module *object.Module) {
+	vm.constants = module.Constants
+	vm.globals = module.Globals
+}
+
+// push the module, running its body in a new frame first if it is not loaded yet, the body leaves the
+// module on the stack with OpEndModule
+func (vm *VM) importModule(compiled *object.CompiledModule) error {
+	if module, ok := vm.modules[compiled]; ok {
+		return vm.push(module)
+	}
+
+	module := &object.Module{
+		Name:      compiled.Name,
+		Constants: compiled.Constants,
+		Globals:   make([]object.Object, compiled.NumGlobals),
+		Exports:   compiled.Exports,
+	}
+	vm.modules[compiled] = module
+
+	err := vm.push(module)
+	if err != nil {
+		return err
+	}
+	body := &object.CompiledFunction{Instructions: compiled.Instructions, SourceMap: compiled.SourceMap}
+	vm.pushFrame(NewFrame(&object.Closure{Fn: body, Module: module}, vm.sp))
+	return nil
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
@@ -305,11 +622,42 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
 
-	return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
+	return operatorError(op, left, right)
+}
+
+// the operators of the binary opcodes
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:                "+",
+	code.OpSub:                "-",
+	code.OpMul:                "*",
+	code.OpDiv:                "/",
+	code.OpMod:                "%",
+	code.OpPow:                "**",
+	code.OpBitAnd:             "&",
+	code.OpBitOr:              "|",
+	code.OpBitXor:             "^",
+	code.OpShiftLeft:          "<<",
+	code.OpShiftRight:         ">>",
+	code.OpEqual:              "==",
+	code.OpNotEqual:           "!=",
+	code.OpGreaterThan:        ">",
+	code.OpGreaterThanOrEqual: ">=",
+	code.OpLessThan:           "<",
+	code.OpLessThanOrEqual:    "<=",
+}
+
+// return the error of a binary operation not supported by its operands, worded like the evaluator's
+func operatorError(op code.Opcode, left, right object.Object) error {
+	if left.Type() != right.Type() && !(isNumber(left) && isNumber(right)) {
+		return fmt.Errorf("type mismatch: %s %s %s", left.Type(), binaryOperators[op], right.Type())
+	}
+	return fmt.Errorf("unknown operator: %s %s %s", left.Type(), binaryOperators[op], right.Type())
 }
 
 func (vm *VM) executeBinaryIntegerOperation(
@@ -328,13 +676,65 @@ func (vm *VM) executeBinaryIntegerOperation(
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("modulo by zero")
+		}
+		result = leftValue % rightValue
+	case code.OpPow:
+		if rightValue < 0 {
+			return fmt.Errorf("negative exponent: %d", rightValue)
+		}
+		result = integerPow(leftValue, rightValue)
+	case code.OpBitAnd:
+		result = leftValue & rightValue
+	case code.OpBitOr:
+		result = leftValue | rightValue
+	case code.OpBitXor:
+		result = leftValue ^ rightValue
+	case code.OpShiftLeft, code.OpShiftRight:
+		if rightValue < 0 {
+			return fmt.Errorf("shift amount must not be negative, got %d", rightValue)
+		}
+		if op == code.OpShiftLeft {
+			result = leftValue << rightValue
+		} else {
+			result = leftValue >> rightValue
+		}
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return operatorError(op, left, right)
 	}
 	return vm.push(&object.Integer{Value: result})
 }
 
+// integers are promoted to float when mixed with floats
+func (vm *VM) executeBinaryFloatOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	var result float64
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	default:
+		return operatorError(op, left, right)
+	}
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeBinaryStringOperation(
 	op code.Opcode,
 	left, right object.Object,
@@ -347,7 +747,7 @@ func (vm *VM) executeBinaryStringOperation(
 	case code.OpAdd:
 		result = leftValue + rightValue
 	default:
-		return fmt.Errorf("unknown string operator: %d", op)
+		return operatorError(op, left, right)
 	}
 	return vm.push(&object.String{Value: result})
 }
@@ -362,6 +762,12 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	if leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ {
 		return vm.executeIntegerComparison(op, left, right)
 	}
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+	if leftType == object.STRING_OBJ && rightType == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left, right)
+	}
 
 	switch op {
 	case code.OpEqual:
@@ -369,7 +775,7 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	case code.OpNotEqual:
 		return vm.push(nativeBoolToBooleanObject(right != left))
 	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+		return operatorError(op, left, right)
 	}
 }
 
@@ -387,8 +793,56 @@ func (vm *VM) executeIntegerComparison(
 		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return operatorError(op, left, right)
+	}
+}
+
+func (vm *VM) executeStringComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	default:
+		return operatorError(op, left, right)
+	}
+}
+
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return operatorError(op, left, right)
 	}
 }
 
@@ -406,12 +860,23 @@ func (vm *VM) executeBangOperator() error {
 
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
-	if operand.Type() != object.INTEGER_OBJ {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
 	}
+}
 
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+	integer, ok := operand.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("unknown operator: ~%s", operand.Type())
+	}
+	return vm.push(&object.Integer{Value: ^integer.Value})
 }
 
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
@@ -434,7 +899,7 @@ func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 		pair := object.HashPair{Key: key, Value: value}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, fmt.Errorf("unhashable as hash key: %s", key.Type())
 		}
 
 		hashedPairs[hashKey.HashKey()] = pair
@@ -447,28 +912,102 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return vm.executeModuleIndex(left.(*object.Module), index.(*object.String))
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
 }
 
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
-	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if i < 0 || i > max {
+	elements := array.(*object.Array).Elements
+	i, ok := object.Position(index.(*object.Integer).Value, len(elements))
+	if !ok {
 		return vm.push(Null)
 	}
-	return vm.push(arrayObject.Elements[i])
+	return vm.push(elements[i])
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
+	i, ok := object.Position(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return vm.push(Null)
+	}
+	return vm.push(&object.String{Value: string(runes[i])})
+}
+
+func (vm *VM) executeSetIndex(left, index, value object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i, ok := object.Position(index.(*object.Integer).Value, len(elements))
+		if !ok {
+			return fmt.Errorf("index out of range: %d (length %d)", index.(*object.Integer).Value, len(elements))
+		}
+		elements[i] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unhashable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return fmt.Errorf("index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) executeRest(obj object.Object, start int) error {
+	array, ok := obj.(*object.Array)
+	if !ok {
+		return fmt.Errorf("rest element requires an array, got %s", obj.Type())
+	}
+
+	elements := []object.Object{}
+	if start < len(array.Elements) {
+		elements = append(elements, array.Elements[start:]...)
+	}
+	return vm.push(&object.Array{Elements: elements})
+}
+
+// pop numKeys keys and an object and push whether the object is a hash containing all of the keys
+func (vm *VM) executeMatchHash(numKeys int) error {
+	keys := vm.stack[vm.sp-numKeys : vm.sp]
+	hash, ok := vm.stack[vm.sp-numKeys-1].(*object.Hash)
+	vm.sp = vm.sp - numKeys - 1
+
+	for i := 0; ok && i < len(keys); i++ {
+		key, hashable := keys[i].(object.Hashable)
+		if !hashable {
+			return fmt.Errorf("unhashable as hash key: %s", keys[i].Type())
+		}
+		_, ok = hash.Pairs[key.HashKey()]
+	}
+	return vm.push(nativeBoolToBooleanObject(ok))
+}
+
+func (vm *VM) executeModuleIndex(module *object.Module, name *object.String) error {
+	value, ok := module.Export(name.Value)
+	if !ok {
+		return fmt.Errorf("module %s does not export %s", module.Name, name.Value)
+	}
+	if value == nil {
+		return vm.push(Null)
+	}
+	return vm.push(value)
 }
 
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return fmt.Errorf("unhashable as hash key: %s", index.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -478,6 +1017,25 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(pair.Value)
 }
 
+func (vm *VM) executeIterNext(pos int, numValues int) error {
+	iterator := vm.pop().(*object.Iterator)
+
+	key, value, ok := iterator.Next()
+	if !ok {
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+
+	if numValues == 1 {
+		return vm.push(iterator.Single(key, value))
+	}
+	err := vm.push(key)
+	if err != nil {
+		return err
+	}
+	return vm.push(value)
+}
+
 func (vm *VM) executeCall(numArgs int) error {
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
@@ -486,28 +1044,67 @@ func (vm *VM) executeCall(numArgs int) error {
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-function and non-built-in")
+		return fmt.Errorf("not a function: %s", callee.Type())
 	}
 }
 
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
-			cl.Fn.NumParameters, numArgs)
+	fn := cl.Fn
+	if numArgs < fn.MinArity || (fn.MaxArity >= 0 && numArgs > fn.MaxArity) {
+		return errors.New(object.WrongArity(fn.MinArity, fn.MaxArity, numArgs))
+	}
+
+	// the parameters with a default are null until the callee replaces them
+	for ; numArgs < fn.NumParameters; numArgs++ {
+		err := vm.push(Null)
+		if err != nil {
+			return err
+		}
+	}
+	if fn.MaxArity < 0 {
+		rest := make([]object.Object, numArgs-fn.NumParameters)
+		copy(rest, vm.stack[vm.sp-len(rest):vm.sp])
+		vm.sp -= len(rest)
+
+		err := vm.push(&object.Array{Elements: rest})
+		if err != nil {
+			return err
+		}
+		numArgs = fn.NumParameters + 1
 	}
 
 	frame := NewFrame(cl, vm.sp-numArgs)
 	vm.pushFrame(frame)
 
+	// clear the locals, a cell left on the stack by an earlier call must not be written through
+	for i := vm.sp; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
 	return nil
 }
 
+// return the value held by a cell, other objects are returned as is
+func deref(obj object.Object) object.Object {
+	if cell, ok := obj.(*object.Cell); ok {
+		if cell.Value == nil {
+			return Null
+		}
+		return cell.Value
+	}
+	return obj
+}
+
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 	result := builtin.Fn(args...)
 	vm.sp = vm.sp - numArgs - 1
 
+	// an error of a builtin is a runtime error which can be caught
+	if err, ok := result.(*object.Error); ok {
+		return errors.New(err.Message)
+	}
+
 	if result != nil {
 		vm.push(result)
 	} else {
@@ -530,7 +1127,7 @@ func (vm *VM) pushClosure(constIndex int, numFree int) error {
 	}
 	vm.sp = vm.sp - numFree
 
-	closure := &object.Closure{Fn: function, Free: free}
+	closure := &object.Closure{Fn: function, Free: free, Module: vm.currentFrame().cl.Module}
 	return vm.push(closure)
 }
 
@@ -541,6 +1138,31 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return False
 }
 
+// compute base ** exp by squaring, exp must not be negative
+func integerPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// convert INTEGER or FLOAT object to float64
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.Boolean:
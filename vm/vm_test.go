@@ -1,12 +1,14 @@
 package vm
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
@@ -33,6 +35,45 @@ func TestIntegerVm(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"0xFF + 0o10 + 0b11", 266},
+		{"1_000 * 2", 2000},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+		{"2 * 3 ** 2", 18},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 << 0", 1},
+		{"let x = 8; x >> 0", 8},
+		{"1 << 2 + 1", 8},
+		{"6 & 3 == 2", true},
+		{"1 | 2 ^ 3 & 5", 3},
+		{"0xDEADBEEF & 0xFFFF", 0xBEEF},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestFloatVm(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5.0},
+		{"-1.5", -1.5},
+		{"-(1 - 3.5)", 2.5},
+		{"1e3 / 8", 125.0},
 	}
 
 	for _, tt := range tests {
@@ -68,6 +109,31 @@ func TestBooleanExpressions(t *testing.T) {
 		{"!!false", false},
 		{"!!5", true},
 		{"!(if (false) {5;})", true},
+		{"1.5 > 1", true},
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"2.5 != 2.5", false},
+		{"0.1 + 0.2 > 0.3", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"2 <= 1.5", false},
+		{"let a = 1; a < (a = 5)", true},
+		{"let a = 5; a <= (a = 1)", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"0 || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
 	}
 
 	for _, tt := range tests {
@@ -87,6 +153,8 @@ func TestConditionals(t *testing.T) {
 		{"if (1 > 2) {10}", Null},
 		{"if (false) {10}", Null},
 		{"if ((if(false) {true})) {10} else {20}", 20},
+		{"if (true) { let a = 1; }", Null},
+		{"if (false) { 1 } else { }", Null},
 	}
 
 	for _, tt := range tests {
@@ -99,6 +167,7 @@ func TestGlobalLetStatements(t *testing.T) {
 		{"let one = 1; one", 1},
 		{"let one = 1; let two  = 2; one + two", 3},
 		{"let one = 1; let two  = one + one ; one + two", 3},
+		{"let 하나 = 1; let 둘 = 2; 하나 + 둘", 3},
 	}
 
 	for _, tt := range tests {
@@ -111,6 +180,24 @@ func TestStringExpressions(t *testing.T) {
 		{`"monkey"`, "monkey"},
 		{`"mon" +"key"`, "monkey"},
 		{`"mon" +"key" + "banana"`, "monkeybanana"},
+		{"`raw\\n` + `\\d+\n`", "raw\\n\\d+\n"},
+		{`"mon" + "key" == "monkey"`, true},
+		{`"a" != "a"`, false},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"plain ${"string"}"`, "plain string"},
+		{`let user = {"name": "Monkey"}; let items = [1, 2]; "Hello ${user["name"]}, you have ${len(items)} items"`, "Hello Monkey, you have 2 items"},
+		{`"${1.5} ${true} ${[1, "a"]} ${if (false) {1}}"`, "1.5 true [1, a] null"},
+		{`let f = fn(x) { "<${x}>" }; "${f("${1 + 1}")}!"`, "<2>!"},
+		{`let s = ""; let f = fn(x) { s += x; 1 }; f("a") < f("b"); s`, "ab"},
+		{`let str = 1; "${str}"`, "1"},
 	}
 
 	for _, tt := range tests {
@@ -161,7 +248,8 @@ func TestIndexExpressions(t *testing.T) {
 		{"[[1,1,1]][0][0]", 1},
 		{"[][0]", Null},
 		{"[1,2,3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1][-2]", Null},
 		{"{1:1,2:2}[1]", 1},
 		{"{1:1,2:2}[2]", 2},
 		{"{1:1,2:2}[3]", Null},
@@ -173,6 +261,37 @@ func TestIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSlicesAndStringIndexing(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[1, 2, 3, 4][5:]", []int{}},
+		{"let n = 2; [1, 2, 3, 4][n - 1:n + 1]", []int{2, 3}},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a[0]", 1},
+		{"let a = [1, 2, 3]; a[-1] = 9; a[2]", 9},
+		{`"hello"[1]`, "e"},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[5]`, Null},
+		{`"héllo"[1]`, "é"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:-2]`, "hel"},
+		{`"hello"[3:]`, "lo"},
+		{`"héllo"[1:2]`, "é"},
+		{`"hello"[4:2]`, ""},
+		{`let s = "abc"; s[s.len() - 1]`, "c"},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
 func TestCallingFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -379,30 +498,122 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
 			input:    `fn() {1;}(1);`,
-			expected: `wrong number of arguments: want=0, got=1`,
+			expected: `1:1: wrong number of arguments: want=0, got=1`,
 		},
 		{
 			input:    `fn(a) {a}();`,
-			expected: `wrong number of arguments: want=1, got=0`,
+			expected: `1:1: wrong number of arguments: want=1, got=0`,
 		},
 		{
 			input:    `fn(a, b) {a+b}(1);`,
-			expected: `wrong number of arguments: want=2, got=1`,
+			expected: `1:1: wrong number of arguments: want=2, got=1`,
+		},
+		{
+			input:    `fn(a, b = 1) { a }();`,
+			expected: `1:1: wrong number of arguments: want=1 to 2, got=0`,
+		},
+		{
+			input:    `fn(a, b = 1) { a }(1, 2, 3);`,
+			expected: `1:1: wrong number of arguments: want=1 to 2, got=3`,
+		},
+		{
+			input:    `fn(a, b, ...c) { a }(1);`,
+			expected: `1:1: wrong number of arguments: want at least 2, got=1`,
 		},
 	}
 
 	for _, tt := range tests {
-		program := parse(tt.input)
-
-		comp := compiler.New()
-		err := comp.Compile(program)
-		require.Nil(t, err, "compiler error")
+		runVmErrorTest(t, tt)
+	}
+}
 
-		vm := New(comp.Bytecode())
-		err = vm.Run()
-		require.NotNil(t, err, "expected VM error but resulted in none.")
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input:    `1 + true`,
+			expected: `1:1: type mismatch: INTEGER + BOOLEAN`,
+		},
+		{
+			input: `
+let f = fn(x) {
+	let y = x * 2;
+	[1, 2][y]
+}
+f("a")`,
+			expected: `3:10: type mismatch: STRING * INTEGER`,
+		},
+		{
+			input:    "let a = 1;\n\n   a(1)",
+			expected: `3:4: not a function: INTEGER`,
+		},
+		{
+			input:    "let x = 1;\nfor (i in x) { i }",
+			expected: `2:1: INTEGER is not iterable`,
+		},
+		{
+			input:    "let a = [1, 2];\na[2] = 3",
+			expected: `2:1: index out of range: 2 (length 2)`,
+		},
+		{
+			input:    "let a = [1, 2];\na[-3] = 3",
+			expected: `2:1: index out of range: -3 (length 2)`,
+		},
+		{
+			input:    `[1, 2]["a":]`,
+			expected: `1:1: slice bounds must be integers, got STRING`,
+		},
+		{
+			input:    "{}[1:2]",
+			expected: `1:1: slice operator not supported: HASH_OBJ`,
+		},
+		{
+			input:    "let h = {};\nh[[1]] = 1",
+			expected: `2:1: unhashable as hash key: ARRAY_OBJ`,
+		},
+		{
+			input:    `"abc"[0] = "x"`,
+			expected: `1:1: index assignment not supported: STRING[INTEGER]`,
+		},
+		{
+			input:    "let x = 0;\n5 % x",
+			expected: `2:1: modulo by zero`,
+		},
+		{
+			input:    "let x = 0;\n5 / x",
+			expected: `2:1: division by zero`,
+		},
+		{
+			input:    "let x = 10;\nx /= 0",
+			expected: `2:1: division by zero`,
+		},
+		{
+			input:    "1 << -1",
+			expected: `1:1: shift amount must not be negative, got -1`,
+		},
+		{
+			input:    "8 >> -2",
+			expected: `1:1: shift amount must not be negative, got -2`,
+		},
+		{
+			input:    "2 ** -1",
+			expected: `1:1: negative exponent: -1`,
+		},
+		{
+			input:    "~1.5",
+			expected: `1:1: unknown operator: ~FLOAT`,
+		},
+		{
+			input:    "let [a, ...b] = 1",
+			expected: `1:1: index operator not supported: INTEGER`,
+		},
+		{
+			input:    `let [...b] = {"a": 1}`,
+			expected: `1:1: rest element requires an array, got HASH_OBJ`,
+		},
+	}
 
-		require.Equal(t, tt.expected, err.Error(), "wrong vm error")
+	for _, tt := range tests {
+		runVmErrorTest(t, tt)
 	}
 }
 
@@ -411,31 +622,25 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
-		{`len(1)`, &object.Error{
-			Message: "argument to `len` not supported, got INTEGER",
-		}},
-		{`len("one", "two")`, &object.Error{
-			Message: "wrong number of arguments. got=2, want=1",
-		}},
+		{`len("한글")`, 2},
+		{`str(12)`, "12"},
+		{`str("a")`, "a"},
+		{`len("\u{1F600}!")`, 2},
 		{`len([1,2,3])`, 3},
 		{`len([])`, 0},
 		{`puts("hello", "world!")`, Null},
 		{`first([1,2,3])`, 1},
 		{`first([])`, Null},
-		{`first(1)`, &object.Error{
-			Message: "argument to `first` must be ARRAY, got INTEGER",
-		}},
 		{`last([1,2,3])`, 3},
 		{`last([])`, Null},
-		{`last(1)`, &object.Error{
-			Message: "argument to `last` must be ARRAY, got INTEGER",
-		}},
 		{`rest([1,2,3])`, []int{2, 3}},
 		{`rest([])`, Null},
 		{`push([], 1)`, []int{1}},
-		{`push(1,1)`, &object.Error{
-			Message: "argument to `push` must be ARRAY, got INTEGER",
-		}},
+		{`len(range(10))`, 10},
+		{`len(range(0, 10, 3))`, 4},
+		{`len(range(5, 0, -2))`, 3},
+		{`len(range(5, 0))`, 0},
+		{`str(range(1, 5))`, "range(1, 5)"},
 	}
 
 	for _, tt := range tests {
@@ -443,6 +648,22 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestBuiltinFunctionErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`len(1)`, "1:1: argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "1:1: wrong number of arguments. got=2, want=1"},
+		{`first(1)`, "1:1: argument to `first` must be ARRAY, got INTEGER"},
+		{`last(1)`, "1:1: argument to `last` must be ARRAY, got INTEGER"},
+		{`push(1,1)`, "1:1: argument to `push` must be ARRAY, got INTEGER"},
+		{`range(1, 2, 0)`, "1:1: step of `range` must not be 0"},
+		{`range("a")`, "1:1: arguments to `range` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		runVmErrorTest(t, tt)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -576,6 +797,498 @@ func TestRecursiveFunctions(t *testing.T) {
 	}
 }
 
+func TestWhileLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 100000) { let i = i + 1; }; i", 100000},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; if (i == 5) { continue; } let sum = sum + i; }; sum", 50},
+		{"let i = 0; while (true) { if (i == 7) { break; } let i = i + 1; }; i", 7},
+		{`
+		let i = 0;
+		let count = 0;
+		while (i < 3) {
+			let j = 0;
+			while (true) {
+				if (j == 4) { break; }
+				let j = j + 1;
+				let count = count + 1;
+			}
+			let i = i + 1;
+		};
+		count`, 12},
+		{"let f = fn(n) { let i = 0; let total = 0; while (i < n) { let i = i + 1; let total = total + i; } total }; f(10)", 55},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 3) { return i * 2; } } }; f()", 6},
+		{"let i = 0; while (i < 5000) { i += 1; let x = 1 + if (true) { continue } else { 2 }; }; i", 5000},
+		{"let i = 0; while (true) { i += 1; let x = [1, 2, if (i == 3) { break } else { 3 }]; }; i", 3},
+		{"fn() { let i = 0; while (i < 5000) { i += 1; -if (true) { continue } else { 1 }; } i }()", 5000},
+		{"let f = fn(a, b) { a + b }; let n = 0; for (x in range(10)) { n += f(x, if (x == 4) { break } else { 1 }); }; n", 10},
+		{"let n = 0; for (x in range(5000)) { n += 1 + if (x % 2 == 0) { continue } else { x }; }; n", 6252500},
+		{`
+		let make = fn() {
+			let i = 0;
+			let fs = [];
+			while (i < 3) {
+				let x = i;
+				let fs = push(fs, fn() { x * 10 });
+				let i = i + 1;
+			}
+			fs
+		};
+		make()[2]()`, 20},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { let sum = sum + x; }; sum", 10},
+		{`let s = ""; for (k, v in {"b": 2, "a": 1, "c": 3}) { let s = s + k + str(v); }; s`, "a1b2c3"},
+		{`let s = ""; for (k in {2: "x", 1: "y", true: "z"}) { let s = s + str(k); }; s`, "true12"},
+		{`let s = ""; for (c in "한글ab") { let s = c + s; }; s`, "ba글한"},
+		{`let s = ""; for (i, c in "xyz") { let s = s + str(i) + c; }; s`, "0x1y2z"},
+		{"let sum = 0; for (i in range(100000)) { let sum = sum + i; }; sum", 4999950000},
+		{`let s = ""; for (i in range(10, 0, -3)) { let s = s + str(i) + ","; }; s`, "10,7,4,1,"},
+		{"let sum = 0; for (x in range(1, 100)) { if (x > 10) { break; } if (x == 3) { continue; } let sum = sum + x; }; sum", 52},
+		{"let count = 0; for (i in range(3)) { for (j, x in [1, 2]) { let count = count + i * x; } }; count", 9},
+		{"let total = fn(arr) { let t = 0; for (x in arr) { let t = t + x; } t }; total([1, 2, 3])", 6},
+		{"let find = fn(arr, target) { for (i, x in arr) { if (x == target) { return i; } } -1 }; find([5, 6, 7], 7)", 2},
+		{"for (x in []) {}; x", Null},
+		{"let x = 5; for (x in \"\") {}; x", Null},
+		{"fn() { for (x in []) {}; x }()", Null},
+		{"for (k, v in {}) {}; k ?? v ?? 1", 1},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestLoopValues(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 3) { i += 1 }", Null},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break } }", Null},
+		{"for (x in [1, 2]) { x }", Null},
+		{"for (x in [1, 2]) { break }", Null},
+		{"fn() { while (false) {} }()", Null},
+		{"if (true) { for (x in []) {} }", Null},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = x = 5; x + y", 10},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let f = fn() { let x = 1; x += 41; x }; f()", 42},
+		{"let x = 1; let f = fn() { x = 7; }; f(); x", 7},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let make = fn() { let n = 0; [fn() { n += 1 }, fn() { n }] }; let p = make(); p[0](); p[0](); p[1]()", 2},
+		{"let f = fn() { let n = 1; let g = fn() { fn() { n *= 10 } }; g()(); g()(); n }; f()", 100},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); n += 10; inc(); n }; f()", 12},
+		{"let sum = 0; let i = 0; while (i < 5) { i += 1; sum += i; }; sum", 15},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum", 6},
+		{"let a = [1, 2, 3]; a[1] = 20; a[2] += 10; a", []int{1, 20, 13}},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`, 7},
+		{"let a = [[1, 2]]; a[0][1] *= 3; a[0][1]", 6},
+		{"let n = 0; let next = fn() { n += 1; n - 1 }; let a = [10, 20]; a[next()] += 1; [n, a[0], a[1]]", []int{1, 11, 20}},
+		{"let a = [1]; let b = a; b[0] = 5; a[0]", 5},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"const LIMIT = 100; LIMIT", 100},
+		{"const LIMIT = 100; let f = fn() { LIMIT * 2 }; f()", 200},
+		{"let f = fn(n) { const double = n * 2; fn() { double + 1 } }; f(1)() + f(2)()", 8},
+		{"const [a, ...b] = [1, 2, 3]; a + len(b)", 3},
+		{"let sum = 0; for (x in [1, 2, 3]) { const y = x * 10; sum += y; }\nsum", 60},
+		{"let x = 1; const x = 2; x", 2},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [head, second, ...tail] = [1, 2, 3, 4, 5]; head * 100 + second * 10 + len(tail)", 123},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [a, b, ...rest] = [1]; len(rest)", 0},
+		{"let [...all] = [1, 2]; all", []int{1, 2}},
+		{"let [a, b = 10, c = a + 100] = [1]; a + b + c", 112},
+		{"let [a = 5] = [0]; a", 0},
+		{`let {name, age} = {"name": "kim", "age": 30}; name + str(age)`, "kim30"},
+		{`let {name, age = 20} = {"name": "lee"}; age`, 20},
+		{`let {name: n, "home town": town} = {"name": "park", "home town": "seoul"}; n + town`, "parkseoul"},
+		{`let {address: {city}, tags: [first, ...others]} = {"address": {"city": "busan"}, "tags": ["a", "b", "c"]}; city + first + str(len(others))`, "busana2"},
+		{"let [[a, b], [c, d]] = [[1, 2], [3, 4]]; a * 1000 + b * 100 + c * 10 + d", 1234},
+		{`let [{x}, {x: y}] = [{"x": 1}, {"x": 2}]; x + y`, 3},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([10, 3])", 7},
+		{"let f = fn(xs) { let [first, ...rest] = xs; fn() { first + len(rest) } }; f([10, 20, 30])()", 12},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn() { let [a] = [1]; }; f()", Null},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f()", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f(5)", 15},
+		{"let n = 0; let next = fn() { n += 1; n }; let f = fn(a = next()) { a }; f(); f(); f()", 3},
+		{"let f = fn(a, ...rest) { len(rest) }; f(1)", 0},
+		{"let f = fn(a, ...rest) { len(rest) }; f(1, 2, 3)", 2},
+		{"let f = fn(...args) { args }; f(1, 2, 3)", []int{1, 2, 3}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1)", []int{1, 2, 0}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)", []int{1, 5, 2}},
+		{"let sum = fn(...xs) { let t = 0; for (x in xs) { t += x; } t }; sum(1, 2, 3, 4)", 10},
+		{"let make = fn(base) { fn(x, step = base) { x + step } }; make(100)(1)", 101},
+		{"let f = fn(a, ...rest) { fn() { a + len(rest) } }; f(10, 1, 1)()", 12},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestPipelineAndMethodCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2)", 3},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3) |> sub(2)", 5},
+		{"[1, 2, 3] |> len", 3},
+		{`"abc" |> len()`, 3},
+		{"let sum = fn(xs) { let t = 0; for (x in xs) { t += x; } t }; [1, 2, 3] |> push(4) |> sum", 10},
+		{"let inc = fn(x, by = 1) { x + by }; 1.inc().inc(10)", 12},
+		{"[1, 2, 3].push(4).len()", 4},
+		{`"hello".len() + [1].len()`, 6},
+		{"let f = fn(x) { fn(y) { x * y } }; 3 |> f |> fn(g) { g(5) }", 15},
+		{"let xs = [1, 2, 3]; xs.first() + xs.last() |> fn(x) { x * 10 }", 40},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (7) { 1 => "one", 2 => "two", _ => "many" }`, "many"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (-1) { -1 => 10, 1 => 20 }", 10},
+		{"match (2.0) { 2 => 1, _ => 0 }", 1},
+		{"match (true) { false => 0, true => 1 }", 1},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, 2, 3]) { [] => 0, [a, b] => a + b, [a, ...rest] => a * 10 + len(rest) }", 12},
+		{"match ([]) { [a, ...rest] => 1, [] => 2 }", 2},
+		{"match ([0, 5]) { [1, x] => x, [0, x] => x * 2 }", 10},
+		{`match ({"type": "user", "name": "kim"}) { {"type": "admin"} => "admin", {"type": "user", "name": n} => n }`, "kim"},
+		{`match ({"a": 1}) { {b} => b, {} => "hash" }`, "hash"},
+		{`match ([{"p": [1, 2]}]) { [{"p": [x, y]}] => x + y }`, 3},
+		{`match ("x") { [a] => a, {a} => a, _ => 0 }`, 0},
+		{"match (5) { n if n < 0 => -1, n if n > 0 => 1, _ => 0 }", 1},
+		{"match ([3, 4]) { [a, b] if a > b => a, [a, b] => b }", 4},
+		{"match (5) { _ => { let x = 2; x * 3 } }", 6},
+		{"match (3) { 1 => 1 }", Null},
+		{"let f = fn(x) { match (x) { [a, b] => fn() { a * b }, _ => fn() { 0 } } }; f([6, 7])()", 42},
+		{"let describe = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + describe(rest) } }; describe([1, 2, 3, 4])", 10},
+		{"match (match (1) { 1 => [2] }) { [x] => match (x) { 2 => 20 } }", 20},
+		{"let x = 1; match ([2]) { [x] if false => 0, _ => x }", 1},
+		{"let x = 1; match ([2, 3]) { [x] => 0, [a, b] => x }", 1},
+		{"let f = fn() { let x = 1; match ([2]) { [x] if x > 5 => 0, _ => x } }; f()", 1},
+		{"let x = 1; match ([2]) { [x] if x == 2 => x * 10 }", 20},
+		{"let x = 1; match ([2]) { [x] if x == 2 => 0 }; x", 2},
+		{"let x = 1; match ([[5]]) { [[x]] if fn() { x }() == 5 => x }", 5},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestDotAccessAndNullCoalescing(t *testing.T) {
+	tests := []vmTestCase{
+		{`let user = {"name": "kim", "profile": {"email": "kim@example.com"}}; user.profile.email`, "kim@example.com"},
+		{`let user = {"name": "kim"}; user.age`, Null},
+		{`let user = {"name": "kim"}; user?.profile?.email`, Null},
+		{`let user = {}.user; user?.profile?.email`, Null},
+		{`let users = [{"name": "kim"}]; users?.[0]?.name`, "kim"},
+		{`let users = [][0]; users?.[0]?.name`, Null},
+		{"let a = [][0]; a?.[1:2]", Null},
+		{"[1, 2, 3]?.[1:]", []int{2, 3}},
+		{`"abc"?.[:2]`, "ab"},
+		{`let user = {"name": "kim"}; user?.profile?.email ?? "none"`, "none"},
+		{`let user = {"name": "kim"}; user.name ?? "anonymous"`, "kim"},
+		{"[][0] ?? 1", 1},
+		{"0 ?? 1", 0},
+		{"false ?? 1 ?? 2", false},
+		{"[][0] ?? {}.x ?? 3", 3},
+		{"let n = 0; let next = fn() { n += 1; n }; 5 ?? next(); n", 0},
+		{`let h = {"a": 1}; h.a = 2; h.b = h.a + 1; h.a * 10 + h.b`, 23},
+		{`let h = {"count": 1}; h.count += 4; h.count`, 5},
+		{`let inc = fn(h) { h.n + 1 }; {"n": 1}.inc()`, 2},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"try { throw 5 } catch (e) { e * 2 }", 10},
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { throw 1 } catch { 2 }", 2},
+		{`try { throw {"code": 404} } catch (e) { e.code }`, 404},
+		{`let log = ""; try { log += "t"; throw 1 } catch (e) { log += "c" } finally { log += "f" }; log`, "tcf"},
+		{`let log = ""; let x = try { 1 } finally { log += "f" }; log + x.str()`, "f1"},
+		{`let f = fn() { throw "deep" }; let g = fn() { f() + 1 }; try { g() } catch (e) { "caught " + e }`, "caught deep"},
+		{"let f = fn() { throw 1 }; 1 + try { 5 + [1, f()][0] } catch (e) { e + 1 }", 3},
+		{"try { try { throw 1 } finally { 2 } } catch (e) { e + 10 }", 11},
+		{"try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e * 10 }", 20},
+		{`let log = ""; try { try { throw 1 } catch (e) { throw 2 } finally { log += "f" } } catch (e) { log += e.str() }; log`, "f2"},
+		{`let log = ""; let f = fn() { try { return "r" } finally { log += "f" } }; f() + log`, "rf"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; f()", 2},
+		{"let n = 0; let i = 0; while (i < 3) { i += 1; try { if (i == 2) { break } } finally { n += 1 } }\nn * 10 + i", 22},
+		{"let n = 0; let i = 0; while (i < 3) { i += 1; try { if (i == 2) { continue } n += 10 } finally { n += 1 } }\nn", 23},
+		{"let r = 0; for (x in [1, \"a\", 2]) { r += try { x * 2 } catch { 100 } }\nr", 106},
+		{"let f = fn(n) { if (n == 0) { throw \"bottom\" } f(n - 1) }; try { f(50) } catch (e) { e }", "bottom"},
+		{"try { 1(2) } catch (e) { e }", "not a function: INTEGER"},
+		{"try { [1][true] } catch (e) { e }", "index operator not supported: ARRAY_OBJ"},
+		{"try { len(1) } catch (e) { e }", "argument to `len` not supported, got INTEGER"},
+		{"let d = 0; try { 10 / d } catch (e) { e }", "division by zero"},
+		{"try { push(len(1), 2) } catch (e) { 0 }", 0},
+	}
+
+	for _, tt := range tests {
+		runVmTest(t, tt)
+	}
+}
+
+func TestUncaughtExceptions(t *testing.T) {
+	tests := []vmTestCase{
+		{`throw "boom"`, "1:1: uncaught exception: boom"},
+		{"let f = fn() {\n  throw [1]\n}\ntry { f() } finally { 1 }", "2:3: uncaught exception: [1]"},
+		{"try { throw 1 } finally { 2 }", "1:7: uncaught exception: 1"},
+		{"try { 1 / 0 } finally { 2 }", "1:7: division by zero"},
+		{"try { throw 1 } catch (e) { throw e + 1 } finally { 3 }", "1:29: uncaught exception: 2"},
+		{"try { try { throw 1 } finally { 2 } } finally { 3 }", "1:13: uncaught exception: 1"},
+		{"let f = fn() { try { return 1 } catch (e) { 0 } }; f();\nthrow 2", "2:1: uncaught exception: 2"},
+		{"while (true) { try { break } catch (e) { 0 } }\nthrow 3", "2:1: uncaught exception: 3"},
+	}
+
+	for _, tt := range tests {
+		runVmErrorTest(t, tt)
+	}
+}
+
+func TestCaughtErrorsMatchEvaluator(t *testing.T) {
+	tests := []string{
+		"try { 1(2) } catch (e) { e }",
+		"try { [1][true] } catch (e) { e }",
+		"try { let [a] = 1; } catch (e) { e }",
+		"try { len(1) } catch (e) { e }",
+		"try { fn(a) { a }() } catch (e) { e }",
+		"try { -true } catch (e) { e }",
+		`try { 1 + "a" } catch (e) { e }`,
+		"try { {}[[1]] } catch (e) { e }",
+		`try { "a" - "b" } catch (e) { e }`,
+		"try { 1.5 % 2 } catch (e) { e }",
+		"try { 1 < true } catch (e) { e }",
+		`try { "a" <= "b" } catch (e) { e }`,
+		"try { true + true } catch (e) { e }",
+		"try { ~1.5 } catch (e) { e }",
+		"try { let h = {}; h[[1]] = 1 } catch (e) { e }",
+		"try { for (x in 1) {} } catch (e) { e }",
+		"try { 1 / 0 } catch (e) { e }",
+		"try { try { 1 / 0 } finally { 2 } } catch (e) { e }",
+		"try { try { throw [1] } finally { 2 } } catch (e) { e }",
+		`try { throw "thrown" } catch (e) { e }`,
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		require.NoError(t, err, "compiler error")
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		require.NoError(t, err, "vm error")
+
+		evaluated := evaluator.Eval(parse(input), object.NewEnvironment())
+		require.Equal(t, evaluated.Inspect(), vm.LastPoppedStackElement().Inspect(), input)
+	}
+}
+
+func TestConstErrorsMatchEvaluator(t *testing.T) {
+	tests := []string{
+		"const x = 1; x",
+		"let x = 1; const x = 2; x",
+		"for (x in [1, 2]) { const y = x; y }",
+		"let f = fn() { const x = 1; x }; f(); let x = 2; x",
+		"const x = 1; x = 2",
+		"const x = 1; x += 2",
+		"const x = 1; const x = 2;",
+		"const x = 1; let x = 2;",
+		"const [a, ...b] = [1]; b = 2",
+		"const {a} = {}; let [a] = [];",
+		"const x = 1; fn() { let x = 2; }()",
+		"const x = 1; fn(x) { x }(2)",
+		"const x = 1; fn(...x) { x }()",
+		"fn() { const x = 1; fn() { x = 2 } }()()",
+		"const x = 1; for (x in [1]) {}",
+		`const e = 1; try { throw "a"; } catch (e) {}`,
+		"const x = 1; match (2) { x => x }",
+		"const x = 1; if (false) { let x = 2 }; 5",
+		"const x = 1; while (false) { x += 1 }; 5",
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+		compileErr := comp.Compile(parse(input))
+
+		evaluated := evaluator.Eval(parse(input), object.NewEnvironment())
+		evalErr, ok := evaluated.(*object.Error)
+		if compileErr == nil {
+			require.False(t, ok, "evaluator error for %s: %s", input, evaluated.Inspect())
+			continue
+		}
+		require.True(t, ok, "no evaluator error for %s", input)
+		require.Equal(t, compileErr.Error(), fmt.Sprintf("%s: %s", evalErr.Pos, evalErr.Message), input)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []vmTestCase{
+		{`let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 10, 20)`, 10},
+		{`let square = macro(x) { quote(unquote(x) * unquote(x)) }; let n = 3; square(n + 1)`, 16},
+		{`let assert = macro(cond) { quote(if (!(unquote(cond))) { "assertion failed: " + unquote(cond.str()) } else { "ok" }) }; assert(1 + 1 == 3)`, "assertion failed: QUOTE(((1 + 1) == 3))"},
+		{`let add = macro(a, b) { quote(unquote(a) + unquote(b)) }; add(1, 2) + add(3, 4)`, 10},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		expanded, err := evaluator.ExpandMacros(program, env)
+		require.NoError(t, err, "macro expansion error")
+
+		comp := compiler.New()
+		err = comp.Compile(expanded)
+		require.NoError(t, err, "compiler error")
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		require.NoError(t, err, "vm error")
+
+		testExpectObject(t, tt.expected, vm.LastPoppedStackElement())
+	}
+}
+
+func TestMacroLiteralNotExpanded(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse("fn() { macro(x) { x } }"))
+	require.EqualError(t, err, "1:8: macro literal outside of a top-level let statement")
+}
+
+func TestModules(t *testing.T) {
+	sources := map[string]string{
+		"strings.mk": `let suffix = "!";
+export let shout = fn(s) { s + suffix };
+export let fail = fn() { throw "failed in " + suffix };
+export let broken = fn() {
+  1 + suffix
+};`,
+		"counter.mk": "let count = 0; export let next = fn() { count += 1; count };",
+		"adder.mk":   `import "counter.mk" as counter; export let makeAdder = fn(a) { fn(b) { a + b + counter.next() } };`,
+		"values.mk":  "export let [one, {two}] = [1, {\"two\": 2}]; let hidden = 3;",
+	}
+
+	tests := []vmTestCase{
+		{`import "strings.mk" as str; let suffix = "?"; str.shout("hi") + suffix`, "hi!?"},
+		{`import "counter.mk" as a; import "counter.mk" as b; a.next(); b.next()`, 2},
+		{`import "adder.mk" as adder; import "counter.mk" as counter; counter.next(); adder.makeAdder(10)(100)`, 112},
+		{`import "values.mk" as v; v.one + v.two`, 3},
+		{`import "values.mk" as v; v?.one`, 1},
+		{`let x = 5; import "strings.mk" as s; try { s.fail() } catch (e) { e + x.str() }`, "failed in !5"},
+		{`import "counter.mk" as c; let next = c.next; [next(), next()]`, []int{1, 2}},
+		{`import "strings.mk" as str; let f = fn(str) { str.len() }; f("abc")`, 3},
+		{`import "strings.mk" as str; let shout = fn(s) { s + "?" }; let str = "hi"; str.shout()`, "hi?"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		comp.SetImporter(newTestImporter(sources))
+		err := comp.Compile(parse(tt.input))
+		require.NoError(t, err, "compiler error")
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		require.NoError(t, err, "vm error")
+
+		testExpectObject(t, tt.expected, vm.LastPoppedStackElement())
+	}
+
+	errorTests := []vmTestCase{
+		{`import "strings.mk" as str; str.missing`, "1:29: module strings.mk does not export missing"},
+		{`import "values.mk" as v; v.hidden`, "1:26: module values.mk does not export hidden"},
+		{`import "strings.mk" as str; str.broken()`, "strings.mk:5:3: type mismatch: INTEGER + STRING"},
+		{`import "strings.mk" as str; str.fail()`, `strings.mk:3:26: uncaught exception: failed in !`},
+	}
+
+	for _, tt := range errorTests {
+		comp := compiler.New()
+		comp.SetImporter(newTestImporter(sources))
+		err := comp.Compile(parse(tt.input))
+		require.NoError(t, err, "compiler error")
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		require.EqualError(t, err, tt.expected.(string), tt.input)
+	}
+
+	// like the REPL, the import and the call are separate programs sharing their state
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	var constants []object.Object
+	var result object.Object
+	globals := make([]object.Object, GlobalsSize)
+	for _, input := range []string{`import "strings.mk" as str;`, `str.shout("x")`} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		comp.SetImporter(newTestImporter(sources))
+		err := comp.Compile(parse(input))
+		require.NoError(t, err, "compiler error")
+		constants = comp.Bytecode().Constants
+
+		vm := NewWithGlobalState(comp.Bytecode(), globals)
+		require.NoError(t, vm.Run(), "vm error")
+		result = vm.LastPoppedStackElement()
+	}
+	testStringObject(t, "x!", result)
+}
+
 func TestRecursiveFibonacci(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -620,6 +1333,52 @@ func runVmTest(t *testing.T, tt vmTestCase) {
 	testExpectObject(t, tt.expected, stackElem)
 }
 
+// run tt.input expecting the VM to fail with the error message tt.expected
+func runVmErrorTest(t *testing.T, tt vmTestCase) {
+	t.Helper()
+
+	program := parse(tt.input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	require.Nil(t, err, "compiler error")
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	require.NotNil(t, err, "expected VM error but resulted in none.")
+
+	require.Equal(t, tt.expected, err.Error(), "wrong vm error for %s", tt.input)
+}
+
+// importer compiling the sources of a map by import path, each one once
+type testImporter struct {
+	sources map[string]string
+	modules map[string]*object.CompiledModule
+}
+
+func newTestImporter(sources map[string]string) *testImporter {
+	return &testImporter{sources: sources, modules: map[string]*object.CompiledModule{}}
+}
+
+func (ti *testImporter) Import(path string, from string) (*object.CompiledModule, error) {
+	if module, ok := ti.modules[path]; ok {
+		return module, nil
+	}
+	source, ok := ti.sources[path]
+	if !ok {
+		return nil, fmt.Errorf("module %s not found", path)
+	}
+
+	comp := compiler.New()
+	comp.SetImporter(ti)
+	err := comp.Compile(parser.New(lexer.NewWithFilename(source, path)).ParseProgram())
+	if err != nil {
+		return nil, err
+	}
+	ti.modules[path] = comp.Module(path)
+	return ti.modules[path], nil
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -632,6 +1391,8 @@ func testExpectObject(t *testing.T, expected interface{}, actual object.Object)
 	switch expected := expected.(type) {
 	case int:
 		testIntegerObject(t, int64(expected), actual)
+	case float64:
+		testFloatObject(t, expected, actual)
 	case bool:
 		testBooleanObject(t, bool(expected), actual)
 	case *object.Null:
@@ -655,6 +1416,12 @@ func testIntegerObject(t *testing.T, expected int64, actual object.Object) {
 	require.Equal(t, expected, result.Value, "object has wrong value")
 }
 
+func testFloatObject(t *testing.T, expected float64, actual object.Object) {
+	result, ok := actual.(*object.Float)
+	require.True(t, ok)
+	require.Equal(t, expected, result.Value, "object has wrong value")
+}
+
 func testBooleanObject(t *testing.T, expected bool, actual object.Object) {
 	result, ok := actual.(*object.Boolean)
 	require.True(t, ok)
@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
 )
@@ -41,8 +43,16 @@ func main() {
 		p := parser.New(l)
 		program := p.ParseProgram()
 
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			result["ErrorString"] = fmt.Sprintf("macro expansion error: %s", err)
+			return js.ValueOf(result)
+		}
+
 		comp := compiler.New()
-		err := comp.Compile(program)
+		err = comp.Compile(expanded)
 		if err != nil {
 			result["ErrorString"] = fmt.Sprintf("compiler error: %s", err)
 			return js.ValueOf(result)